@@ -1,15 +1,134 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
 
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/logging"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/migrate"
 )
 
+const usage = `usage: migrate [--config FILE] [command]
+
+commands:
+  up            apply all pending migrations (default)
+  down N        roll back the last N applied migrations
+  to VERSION    migrate up or down to VERSION
+  status        list migrations and whether they are applied`
+
 func main() {
-	err := migrate.RunMigrations()
+	configPath := flag.String("config", config.DefaultPath(), "path to the YAML configuration file")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("failed to load config", "error", err)
+	}
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fatal("failed to set up logging", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	if err := run(cfg, command, args); err != nil {
+		fatal("migration failed", "command", command, "error", err)
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func run(cfg config.Config, command string, args []string) error {
+	m, err := migrate.New(cfg)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		if len(args) != 0 {
+			return fmt.Errorf("up takes no arguments\n%s", usage)
+		}
+		if err := m.Bootstrap(ctx); err != nil {
+			return err
+		}
+		if err := m.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) != 1 {
+			return fmt.Errorf("down requires the number of migrations to roll back\n%s", usage)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+		if err := m.Down(ctx, n); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 1 {
+			return fmt.Errorf("to requires a version\n%s", usage)
+		}
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		if err := m.Bootstrap(ctx); err != nil {
+			return err
+		}
+		if err := m.To(ctx, version); err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+
+	slog.Info("successfully migrated")
+	return nil
+}
+
+func printStatus(statuses []migrate.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case status.Missing:
+			state += ", file missing"
+		case status.Modified:
+			state += ", modified"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
 	}
-	log.Printf("successfully migrated")
+	w.Flush()
 }
@@ -2,73 +2,73 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/logging"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/server"
-	"gopkg.in/yaml.v2"
 )
-func loadConfig(path string) (config.Config, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return config.Config{}, err
-	}
-	defer file.Close()
 
-	var cfg config.Config
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&cfg); err != nil {
-		return config.Config{}, err
-	}
-
-	return cfg, nil
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
+	configPath := flag.String("config", config.DefaultPath(), "path to the YAML configuration file")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := loadConfig("config/config.yaml")
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("failed to load config", "error", err)
 	}
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fatal("failed to set up logging", "error", err)
+	}
+	slog.SetDefault(logger)
+
 	srv, err := server.NewServerConfig(cfg)
 	if err != nil {
-		log.Fatalf("failed to initialize server: %v", err)
+		fatal("failed to initialize server", "error", err)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go func() {
-		if err := srv.Run(ctx); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server run failed: %v", err)
-		}
-	}()
-
-	log.Println("Server started")
 	// Wait for interrupt signal to gracefully shutdown the server
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	sig := <-sigChan
-	log.Printf("Received signal: %v", sig)
-
-	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+	go func() {
+		sig := <-sigChan
+		slog.Info("received signal", "signal", sig)
+		cancel()
+	}()
 
-	if err := srv.Close(); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+	slog.Info("server started", "host", cfg.Server.Host, "port", cfg.Server.Port, "storage", cfg.Storage.Driver)
+	if err := srv.Run(ctx); err != nil {
+		slog.Error("server run failed", "error", err)
 	}
 
-	select {
-	case <-ctxShutDown.Done():
-		if ctxShutDown.Err() == context.DeadlineExceeded {
-			log.Println("Shutdown timed out")
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	ctxShutDown, cancelShutDown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutDown()
+
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	if err := srv.Close(ctxShutDown); err != nil {
+		for _, stageErr := range server.ShutdownErrors(err) {
+			if stageErr.Timeout() {
+				slog.Error("shutdown stage timed out", "stage", stageErr.Stage)
+			} else {
+				slog.Error("shutdown stage failed", "stage", stageErr.Stage, "error", stageErr.Err)
+			}
 		}
-	default:
-		log.Println("Server exited properly")
+		cancelShutDown()
+		fatal("server shutdown failed", "error", err)
 	}
-
-}
\ No newline at end of file
+	slog.Info("server exited properly")
+}
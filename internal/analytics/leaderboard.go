@@ -0,0 +1,170 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// DimensionExchange groups the leaderboard by exchange_name.
+const DimensionExchange = "exchange"
+
+// LeaderboardDimensions are the accepted grouping dimensions of the
+// leaderboard besides the algorithm itself.
+var LeaderboardDimensions = []string{DimensionExchange, DimensionPair}
+
+// Metrics the leaderboard can be ranked by.
+const (
+	RankByFills      = "fills"
+	RankByVolume     = "volume"
+	RankByCommission = "commission"
+	RankBySlippage   = "slippage"
+	RankByPnL        = "pnl"
+	RankByNetPnL     = "net_pnl"
+)
+
+// RankMetrics are the accepted ranking metrics, the default first.
+var RankMetrics = []string{RankByPnL, RankByNetPnL, RankByFills, RankByVolume, RankByCommission, RankBySlippage}
+
+// AlgorithmRank is one row of the leaderboard. Volume, commission and PnL
+// are in QuoteCurrency: rows are never merged across quote currencies, so a
+// row that spans several pairs only adds up amounts of the same currency.
+type AlgorithmRank struct {
+	Rank                int    `json:"rank"`
+	AlgorithmNamePlaced string `json:"algorithm_name_placed"`
+	ExchangeName        string `json:"exchange_name,omitempty"`
+	Pair                string `json:"pair,omitempty"`
+	QuoteCurrency       string `json:"quote_currency"`
+
+	Fills      uint64  `json:"fills"`
+	Volume     float64 `json:"volume"`
+	Commission float64 `json:"commission"`
+	// AvgSlippageBps is the mean slippage versus the touch of the orders
+	// with a usable top of book. It is omitted when there are none.
+	AvgSlippageBps *float64 `json:"avg_slippage_bps,omitempty"`
+	// RealizedPnL sums the realized PnL of ComputePnL of every exchange and
+	// pair of the row.
+	RealizedPnL float64 `json:"realized_pnl"`
+	// NetPnL is RealizedPnL minus Commission.
+	NetPnL float64 `json:"net_pnl"`
+}
+
+// RankAlgorithms merges activity and pnls, which are per algorithm, exchange
+// and pair, into one row per algorithm, quote currency and the dimensions of
+// groupBy, and ranks the rows by metric. pnls are computed by ComputePnL with
+// GroupByAlgorithm. Rows are ranked from the highest value down, or from the
+// lowest up when ascending is set; rows without a value, such as those
+// without slippage, come last.
+func RankAlgorithms(activity []*model.AlgorithmActivity, pnls []*PnL, groupBy []string, metric string, ascending bool) []*AlgorithmRank {
+	grouped := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		grouped[dimension] = true
+	}
+
+	type key struct {
+		algorithm, exchange, pair, quote string
+	}
+	type slippage struct {
+		bps    float64
+		orders uint64
+	}
+	rows := make(map[key]*AlgorithmRank)
+	slippages := make(map[*AlgorithmRank]*slippage)
+	ranks := []*AlgorithmRank{}
+	rowOf := func(algorithm, exchange, pair string) *AlgorithmRank {
+		k := key{algorithm: algorithm, quote: quoteCurrency(pair)}
+		if grouped[DimensionExchange] {
+			k.exchange = exchange
+		}
+		if grouped[DimensionPair] {
+			k.pair = pair
+		}
+		row, ok := rows[k]
+		if !ok {
+			row = &AlgorithmRank{AlgorithmNamePlaced: k.algorithm, ExchangeName: k.exchange, Pair: k.pair, QuoteCurrency: k.quote}
+			rows[k] = row
+			slippages[row] = &slippage{}
+			ranks = append(ranks, row)
+		}
+		return row
+	}
+
+	for _, a := range activity {
+		row := rowOf(a.AlgorithmNamePlaced, a.ExchangeName, a.Pair)
+		row.Fills += a.Fills
+		row.Volume += a.BuyNotional + a.SellNotional
+		row.Commission += a.Commission
+		slippages[row].bps += a.SlippageBps
+		slippages[row].orders += a.SlippageOrders
+	}
+	for _, pnl := range pnls {
+		row := rowOf(pnl.AlgorithmNamePlaced, pnl.ExchangeName, pnl.Pair)
+		row.RealizedPnL += pnl.RealizedPnL
+	}
+
+	for _, row := range ranks {
+		row.NetPnL = row.RealizedPnL - row.Commission
+		if s := slippages[row]; s.orders > 0 {
+			avgSlippageBps := s.bps / float64(s.orders)
+			row.AvgSlippageBps = &avgSlippageBps
+		}
+	}
+
+	sort.SliceStable(ranks, func(i, j int) bool {
+		a, b := ranks[i], ranks[j]
+		va, okA := rankValue(a, metric)
+		vb, okB := rankValue(b, metric)
+		if okA != okB {
+			return okA
+		}
+		if okA && va != vb {
+			if ascending {
+				return va < vb
+			}
+			return va > vb
+		}
+		if a.AlgorithmNamePlaced != b.AlgorithmNamePlaced {
+			return a.AlgorithmNamePlaced < b.AlgorithmNamePlaced
+		}
+		if a.ExchangeName != b.ExchangeName {
+			return a.ExchangeName < b.ExchangeName
+		}
+		if a.Pair != b.Pair {
+			return a.Pair < b.Pair
+		}
+		return a.QuoteCurrency < b.QuoteCurrency
+	})
+	for i, row := range ranks {
+		row.Rank = i + 1
+	}
+	return ranks
+}
+
+// rankValue returns the value of row that metric ranks by, and false when
+// row has none.
+func rankValue(row *AlgorithmRank, metric string) (float64, bool) {
+	switch metric {
+	case RankByFills:
+		return float64(row.Fills), true
+	case RankByVolume:
+		return row.Volume, true
+	case RankByCommission:
+		return row.Commission, true
+	case RankBySlippage:
+		if row.AvgSlippageBps == nil {
+			return 0, false
+		}
+		return *row.AvgSlippageBps, true
+	case RankByNetPnL:
+		return row.NetPnL, true
+	default:
+		return row.RealizedPnL, true
+	}
+}
+
+// quoteCurrency returns the QUOTE part of a BASE/QUOTE pair.
+func quoteCurrency(pair string) string {
+	_, quote, _ := strings.Cut(pair, "/")
+	return quote
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestRankAlgorithms(t *testing.T) {
+	activity := []*model.AlgorithmActivity{
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", Fills: 3,
+			BuyQty: 2, BuyNotional: 200, SellQty: 1, SellNotional: 110, Commission: 1, SlippageBps: 6, SlippageOrders: 3},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "kraken", Pair: "ETH/USDT", Fills: 2,
+			BuyQty: 1, BuyNotional: 10, SellQty: 1, SellNotional: 9, SlippageBps: 10, SlippageOrders: 1},
+		{AlgorithmNamePlaced: "sniper", ExchangeName: "binance", Pair: "BTC/USDT", Fills: 10,
+			BuyQty: 1, BuyNotional: 100, SellQty: 1, SellNotional: 102, Commission: 0.5},
+	}
+	pnls := []*PnL{
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", RealizedPnL: 10},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "kraken", Pair: "ETH/USDT", RealizedPnL: -1},
+		{AlgorithmNamePlaced: "sniper", ExchangeName: "binance", Pair: "BTC/USDT", RealizedPnL: 2},
+	}
+
+	ranks := RankAlgorithms(activity, pnls, nil, RankByPnL, false)
+	if len(ranks) != 2 || ranks[0].AlgorithmNamePlaced != "twap" || ranks[1].AlgorithmNamePlaced != "sniper" {
+		t.Fatalf("expected twap ahead of sniper, but got %+v", ranks)
+	}
+	twap, sniper := ranks[0], ranks[1]
+	if twap.Rank != 1 || sniper.Rank != 2 || twap.ExchangeName != "" || twap.Pair != "" || twap.QuoteCurrency != "USDT" || twap.Fills != 5 {
+		t.Errorf("unexpected twap row %+v", twap)
+	}
+	assertFloat(t, "twap realized_pnl", 9, &twap.RealizedPnL)
+	assertFloat(t, "twap net_pnl", 8, &twap.NetPnL)
+	assertFloat(t, "twap volume", 329, &twap.Volume)
+	assertFloat(t, "twap avg_slippage_bps", 4, twap.AvgSlippageBps)
+	if sniper.AvgSlippageBps != nil {
+		t.Errorf("expected no slippage for sniper, but got %v", *sniper.AvgSlippageBps)
+	}
+
+	ranks = RankAlgorithms(activity, pnls, nil, RankByFills, false)
+	if ranks[0].AlgorithmNamePlaced != "sniper" {
+		t.Errorf("expected sniper to have the most fills, but got %+v", ranks[0])
+	}
+	// Rows without slippage come last in either direction.
+	for _, ascending := range []bool{true, false} {
+		ranks = RankAlgorithms(activity, pnls, nil, RankBySlippage, ascending)
+		if ranks[1].AlgorithmNamePlaced != "sniper" {
+			t.Errorf("ascending %v: expected sniper last, but got %+v", ascending, ranks[1])
+		}
+	}
+
+	ranks = RankAlgorithms(activity, pnls, []string{DimensionExchange, DimensionPair}, RankByVolume, true)
+	if len(ranks) != 3 || ranks[0].ExchangeName != "kraken" || ranks[0].Pair != "ETH/USDT" {
+		t.Errorf("expected the kraken row with the least volume first, but got %+v", ranks)
+	}
+}
+
+// TestRankAlgorithms_RealizedPnL ranks by the realized PnL of ComputePnL,
+// which closes the buy at 100 first and leaves the buy at 50 open.
+func TestRankAlgorithms_RealizedPnL(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	orders := []*model.HistoryOrder{
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", Side: "buy", BaseQty: 1, Price: 100, TimePlaced: start},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", Side: "sell", BaseQty: 1, Price: 110, TimePlaced: start.Add(time.Minute)},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", Side: "buy", BaseQty: 1, Price: 50, TimePlaced: start.Add(2 * time.Minute)},
+	}
+	activity := []*model.AlgorithmActivity{{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT",
+		Fills: 3, BuyQty: 2, BuyNotional: 150, SellQty: 1, SellNotional: 110, Commission: 1}}
+
+	ranks := RankAlgorithms(activity, ComputePnL(orders, CostFIFO, GroupByAlgorithm), nil, RankByPnL, false)
+	assertFloat(t, "realized_pnl", 10, &ranks[0].RealizedPnL)
+	assertFloat(t, "net_pnl", 9, &ranks[0].NetPnL)
+}
+
+func TestRankAlgorithms_QuoteCurrencies(t *testing.T) {
+	activity := []*model.AlgorithmActivity{
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", Fills: 2,
+			BuyQty: 1, BuyNotional: 60000, SellQty: 1, SellNotional: 60100},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "ETH/BTC", Fills: 2,
+			BuyQty: 1, BuyNotional: 0.05, SellQty: 1, SellNotional: 0.06},
+	}
+	pnls := []*PnL{
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "BTC/USDT", RealizedPnL: 100},
+		{AlgorithmNamePlaced: "twap", ExchangeName: "binance", Pair: "ETH/BTC", RealizedPnL: 0.01},
+	}
+
+	ranks := RankAlgorithms(activity, pnls, nil, RankByPnL, false)
+	if len(ranks) != 2 || ranks[0].QuoteCurrency != "USDT" || ranks[1].QuoteCurrency != "BTC" {
+		t.Fatalf("expected a row per quote currency, but got %+v", ranks)
+	}
+	assertFloat(t, "USDT volume", 120100, &ranks[0].Volume)
+	assertFloat(t, "USDT realized_pnl", 100, &ranks[0].RealizedPnL)
+	assertFloat(t, "BTC volume", 0.11, &ranks[1].Volume)
+	assertFloat(t, "BTC realized_pnl", 0.01, &ranks[1].RealizedPnL)
+}
@@ -0,0 +1,107 @@
+// Package analytics computes derived statistics from stored order books and
+// order history.
+package analytics
+
+import (
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// DefaultDepthBps are the distances from mid, in basis points, used when the
+// caller does not ask for specific ones.
+var DefaultDepthBps = []float64{10, 25, 50, 100}
+
+// DepthBand is the cumulative base quantity resting within Bps of mid.
+type DepthBand struct {
+	Bps       float64 `json:"bps"`
+	BidQty    float64 `json:"bid_qty"`
+	AskQty    float64 `json:"ask_qty"`
+	Imbalance float64 `json:"imbalance"`
+}
+
+// OrderBookMetrics describes one order book snapshot. Fields that need both
+// sides of the book are omitted when a side is empty.
+type OrderBookMetrics struct {
+	ID           int64             `json:"id"`
+	SnapshotTime time.Time         `json:"snapshot_time"`
+	BestBid      *model.DepthOrder `json:"best_bid,omitempty"`
+	BestAsk      *model.DepthOrder `json:"best_ask,omitempty"`
+	Spread       *float64          `json:"spread,omitempty"`
+	SpreadBps    *float64          `json:"spread_bps,omitempty"`
+	Mid          *float64          `json:"mid,omitempty"`
+	MicroPrice   *float64          `json:"micro_price,omitempty"`
+	Imbalance    *float64          `json:"imbalance,omitempty"`
+	Depth        []DepthBand       `json:"depth,omitempty"`
+}
+
+// ComputeOrderBookMetrics computes top-of-book metrics of orderBook and the
+// depth within each of depthBps basis points of mid. Levels do not have to be
+// sorted.
+func ComputeOrderBookMetrics(orderBook *model.OrderBook, depthBps []float64) *OrderBookMetrics {
+	metrics := &OrderBookMetrics{
+		ID:           orderBook.ID,
+		SnapshotTime: orderBook.SnapshotTime,
+	}
+	for _, bid := range orderBook.Bids {
+		if metrics.BestBid == nil || bid.Price > metrics.BestBid.Price {
+			metrics.BestBid = &model.DepthOrder{Price: bid.Price, BaseQty: bid.BaseQty}
+		}
+	}
+	for _, ask := range orderBook.Asks {
+		if metrics.BestAsk == nil || ask.Price < metrics.BestAsk.Price {
+			metrics.BestAsk = &model.DepthOrder{Price: ask.Price, BaseQty: ask.BaseQty}
+		}
+	}
+	if metrics.BestBid == nil || metrics.BestAsk == nil {
+		return metrics
+	}
+
+	bid, ask := metrics.BestBid, metrics.BestAsk
+	mid := (bid.Price + ask.Price) / 2
+	spread := ask.Price - bid.Price
+	metrics.Mid = &mid
+	metrics.Spread = &spread
+	if mid != 0 {
+		spreadBps := spread / mid * 1e4
+		metrics.SpreadBps = &spreadBps
+	}
+	if topQty := bid.BaseQty + ask.BaseQty; topQty > 0 {
+		// The micro-price leans towards the side with less quantity, which is
+		// the side more likely to be taken out next.
+		microPrice := (bid.Price*ask.BaseQty + ask.Price*bid.BaseQty) / topQty
+		topImbalance := imbalance(bid.BaseQty, ask.BaseQty)
+		metrics.MicroPrice = &microPrice
+		metrics.Imbalance = &topImbalance
+	}
+
+	for _, bps := range depthBps {
+		band := DepthBand{Bps: bps}
+		lower := mid * (1 - bps/1e4)
+		upper := mid * (1 + bps/1e4)
+		for _, level := range orderBook.Bids {
+			if level.Price >= lower {
+				band.BidQty += level.BaseQty
+			}
+		}
+		for _, level := range orderBook.Asks {
+			if level.Price <= upper {
+				band.AskQty += level.BaseQty
+			}
+		}
+		band.Imbalance = imbalance(band.BidQty, band.AskQty)
+		metrics.Depth = append(metrics.Depth, band)
+	}
+
+	return metrics
+}
+
+// imbalance is (bid - ask) / (bid + ask), ranging from -1 (only asks) to 1
+// (only bids), and 0 when both are empty.
+func imbalance(bid, ask float64) float64 {
+	total := bid + ask
+	if total == 0 {
+		return 0
+	}
+	return (bid - ask) / total
+}
@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestComputeOrderBookMetrics(t *testing.T) {
+	orderBook := &model.OrderBook{
+		Asks: []model.DepthOrder{{Price: 101, BaseQty: 2}, {Price: 100.1, BaseQty: 1}, {Price: 100.5, BaseQty: 4}},
+		Bids: []model.DepthOrder{{Price: 99.5, BaseQty: 5}, {Price: 99.9, BaseQty: 3}},
+	}
+
+	metrics := ComputeOrderBookMetrics(orderBook, []float64{10, 60})
+
+	if metrics.BestBid == nil || *metrics.BestBid != (model.DepthOrder{Price: 99.9, BaseQty: 3}) {
+		t.Errorf("unexpected best bid %v", metrics.BestBid)
+	}
+	if metrics.BestAsk == nil || *metrics.BestAsk != (model.DepthOrder{Price: 100.1, BaseQty: 1}) {
+		t.Errorf("unexpected best ask %v", metrics.BestAsk)
+	}
+	assertFloat(t, "mid", 100, metrics.Mid)
+	assertFloat(t, "spread", 0.2, metrics.Spread)
+	assertFloat(t, "spread_bps", 20, metrics.SpreadBps)
+	// (99.9*1 + 100.1*3) / 4
+	assertFloat(t, "micro_price", 100.05, metrics.MicroPrice)
+	assertFloat(t, "imbalance", 0.5, metrics.Imbalance)
+
+	expected := []DepthBand{
+		// Within 10 bps of 100: bids >= 99.9, asks <= 100.1.
+		{Bps: 10, BidQty: 3, AskQty: 1, Imbalance: 0.5},
+		// Within 60 bps of 100: bids >= 99.4, asks <= 100.6.
+		{Bps: 60, BidQty: 8, AskQty: 5, Imbalance: 3.0 / 13},
+	}
+	if len(metrics.Depth) != len(expected) {
+		t.Fatalf("expected %d depth bands, but got %v", len(expected), metrics.Depth)
+	}
+	for i, band := range metrics.Depth {
+		if band.Bps != expected[i].Bps || !almostEqual(band.BidQty, expected[i].BidQty) ||
+			!almostEqual(band.AskQty, expected[i].AskQty) || !almostEqual(band.Imbalance, expected[i].Imbalance) {
+			t.Errorf("expected depth band %+v, but got %+v", expected[i], band)
+		}
+	}
+}
+
+func TestComputeOrderBookMetrics_OneSided(t *testing.T) {
+	orderBook := &model.OrderBook{
+		Bids: []model.DepthOrder{{Price: 99.5, BaseQty: 5}},
+	}
+
+	metrics := ComputeOrderBookMetrics(orderBook, DefaultDepthBps)
+
+	if metrics.BestBid == nil || metrics.BestAsk != nil {
+		t.Errorf("expected only a best bid, but got %v and %v", metrics.BestBid, metrics.BestAsk)
+	}
+	if metrics.Mid != nil || metrics.Spread != nil || metrics.MicroPrice != nil || metrics.Depth != nil {
+		t.Errorf("expected metrics that need both sides to be omitted, but got %+v", metrics)
+	}
+}
+
+func assertFloat(t *testing.T, name string, expected float64, got *float64) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("expected %s %v, but it is not set", name, expected)
+		return
+	}
+	if !almostEqual(*got, expected) {
+		t.Errorf("expected %s %v, but got %v", name, expected, *got)
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
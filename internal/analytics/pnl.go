@@ -0,0 +1,197 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// CostMethod selects how sells are matched against earlier buys, and buys
+// against earlier sells when the position is short.
+type CostMethod string
+
+const (
+	// CostFIFO closes the oldest open lots first.
+	CostFIFO CostMethod = "fifo"
+	// CostAverage closes against the average price of the open position.
+	CostAverage CostMethod = "average"
+)
+
+// CostMethods are the accepted values of CostMethod.
+var CostMethods = []string{string(CostFIFO), string(CostAverage)}
+
+// PnLGrouping selects the positions PnL is computed for.
+type PnLGrouping string
+
+const (
+	// GroupByClient keeps one position per client_name, exchange_name, label
+	// and pair.
+	GroupByClient PnLGrouping = "client"
+	// GroupByAlgorithm keeps one position per algorithm_name_placed,
+	// exchange_name and pair, as if every algorithm traded on its own.
+	GroupByAlgorithm PnLGrouping = "algorithm"
+)
+
+// PnLGroupings are the accepted values of PnLGrouping.
+var PnLGroupings = []string{string(GroupByClient), string(GroupByAlgorithm)}
+
+// qtyEpsilon is the base quantity below which a position or lot is treated
+// as closed, so float rounding does not leave dust positions open.
+const qtyEpsilon = 1e-12
+
+// PnL sums the fills of one position. Quantities are in the base currency;
+// prices, turnover, commission and PnL in the quote currency. The fields of
+// the group key that do not apply to the grouping are omitted.
+type PnL struct {
+	ClientName          string `json:"client_name,omitempty"`
+	ExchangeName        string `json:"exchange_name"`
+	Label               string `json:"label,omitempty"`
+	Pair                string `json:"pair"`
+	AlgorithmNamePlaced string `json:"algorithm_name_placed,omitempty"`
+
+	Fills      int     `json:"fills"`
+	BuyQty     float64 `json:"buy_qty"`
+	SellQty    float64 `json:"sell_qty"`
+	Turnover   float64 `json:"turnover"`
+	Commission float64 `json:"commission"`
+	// RealizedPnL is the PnL of the closed quantity before commission.
+	RealizedPnL float64 `json:"realized_pnl"`
+	// NetPnL is RealizedPnL minus Commission.
+	NetPnL float64 `json:"net_pnl"`
+	// NetPosition is positive when long and negative when short.
+	NetPosition float64 `json:"net_position"`
+	// AvgEntryPrice is the average price of the open position. It is
+	// omitted when the position is flat.
+	AvgEntryPrice *float64 `json:"avg_entry_price,omitempty"`
+}
+
+// lot is an open quantity bought or sold at price. qty is negative for
+// short lots.
+type lot struct {
+	qty   float64
+	price float64
+}
+
+// position matches fills of one group with method.
+type position struct {
+	pnl    *PnL
+	method CostMethod
+	// lots holds the open lots, oldest first, all on the same side. The
+	// average method keeps a single lot at the average price.
+	lots []lot
+}
+
+// ComputePnL computes realized PnL of orders with method, per group of
+// groupBy. Every order is a fill; the position of each group is assumed flat
+// before its first order. Orders do not have to be sorted. Groups are
+// returned sorted by their key.
+func ComputePnL(orders []*model.HistoryOrder, method CostMethod, groupBy PnLGrouping) []*PnL {
+	sorted := append([]*model.HistoryOrder(nil), orders...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].TimePlaced.Equal(sorted[j].TimePlaced) {
+			return sorted[i].TimePlaced.Before(sorted[j].TimePlaced)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	// The group key is the PnL with only its key fields set.
+	positions := make(map[PnL]*position)
+	var groups []*PnL
+	for _, order := range sorted {
+		key := PnL{ExchangeName: order.ExchangeName, Pair: order.Pair}
+		if groupBy == GroupByAlgorithm {
+			key.AlgorithmNamePlaced = order.AlgorithmNamePlaced
+		} else {
+			key.ClientName = order.ClientName
+			key.Label = order.Label
+		}
+		p, ok := positions[key]
+		if !ok {
+			group := key
+			p = &position{pnl: &group, method: method}
+			positions[key] = p
+			groups = append(groups, p.pnl)
+		}
+		p.fill(order)
+	}
+
+	for _, p := range positions {
+		p.close()
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		for _, field := range [][2]string{
+			{a.ClientName, b.ClientName},
+			{a.AlgorithmNamePlaced, b.AlgorithmNamePlaced},
+			{a.ExchangeName, b.ExchangeName},
+			{a.Label, b.Label},
+			{a.Pair, b.Pair},
+		} {
+			if field[0] != field[1] {
+				return field[0] < field[1]
+			}
+		}
+		return false
+	})
+	return groups
+}
+
+func (p *position) fill(order *model.HistoryOrder) {
+	p.pnl.Fills++
+	p.pnl.Turnover += order.BaseQty * order.Price
+	p.pnl.Commission += order.CommissionQuoteQty
+
+	qty := order.BaseQty
+	if order.Side == "sell" {
+		p.pnl.SellQty += order.BaseQty
+		qty = -qty
+	} else {
+		p.pnl.BuyQty += order.BaseQty
+	}
+
+	// Close open lots of the other side first; what remains opens or adds
+	// to a lot on the side of the fill.
+	for len(p.lots) > 0 && math.Abs(qty) > qtyEpsilon && (p.lots[0].qty > 0) != (qty > 0) {
+		open := &p.lots[0]
+		closed := math.Min(math.Abs(qty), math.Abs(open.qty))
+		if open.qty > 0 {
+			p.pnl.RealizedPnL += (order.Price - open.price) * closed
+			open.qty -= closed
+			qty += closed
+		} else {
+			p.pnl.RealizedPnL += (open.price - order.Price) * closed
+			open.qty += closed
+			qty -= closed
+		}
+		if math.Abs(open.qty) <= qtyEpsilon {
+			p.lots = p.lots[1:]
+		}
+	}
+	if math.Abs(qty) <= qtyEpsilon {
+		return
+	}
+	if p.method == CostAverage && len(p.lots) > 0 {
+		open := &p.lots[0]
+		open.price = (open.qty*open.price + qty*order.Price) / (open.qty + qty)
+		open.qty += qty
+		return
+	}
+	p.lots = append(p.lots, lot{qty: qty, price: order.Price})
+}
+
+// close fills in the fields derived from the open lots.
+func (p *position) close() {
+	p.pnl.NetPnL = p.pnl.RealizedPnL - p.pnl.Commission
+	var cost float64
+	for _, open := range p.lots {
+		p.pnl.NetPosition += open.qty
+		cost += open.qty * open.price
+	}
+	if math.Abs(p.pnl.NetPosition) > qtyEpsilon {
+		avgEntryPrice := cost / p.pnl.NetPosition
+		p.pnl.AvgEntryPrice = &avgEntryPrice
+	} else {
+		p.pnl.NetPosition = 0
+	}
+}
@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func fill(minute int, algorithm, side string, qty, price, commission float64) *model.HistoryOrder {
+	return &model.HistoryOrder{
+		ID:                  int64(minute),
+		ClientName:          "bot",
+		ExchangeName:        "binance",
+		Pair:                "BTC/USDT",
+		Side:                side,
+		BaseQty:             qty,
+		Price:               price,
+		AlgorithmNamePlaced: algorithm,
+		CommissionQuoteQty:  commission,
+		TimePlaced:          time.Date(2024, 6, 1, 12, minute, 0, 0, time.UTC),
+	}
+}
+
+func TestComputePnL(t *testing.T) {
+	// Out of order on purpose: fills are matched by time_placed.
+	orders := []*model.HistoryOrder{
+		fill(2, "twap", "sell", 1, 120, 0.5),
+		fill(0, "twap", "buy", 1, 100, 0.5),
+		fill(1, "twap", "buy", 1, 110, 0.5),
+	}
+
+	tests := []struct {
+		method   CostMethod
+		realized float64
+		entry    float64
+	}{
+		// The sell closes the lot bought at 100 and leaves the one at 110.
+		{CostFIFO, 20, 110},
+		// The sell closes against the average of 105.
+		{CostAverage, 15, 105},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			groups := ComputePnL(orders, tt.method, GroupByClient)
+			if len(groups) != 1 {
+				t.Fatalf("expected one group, but got %d", len(groups))
+			}
+			pnl := groups[0]
+			if pnl.ClientName != "bot" || pnl.AlgorithmNamePlaced != "" || pnl.Fills != 3 {
+				t.Errorf("unexpected group %+v", pnl)
+			}
+			assertFloat(t, "buy_qty", 2, &pnl.BuyQty)
+			assertFloat(t, "sell_qty", 1, &pnl.SellQty)
+			assertFloat(t, "turnover", 330, &pnl.Turnover)
+			assertFloat(t, "commission", 1.5, &pnl.Commission)
+			assertFloat(t, "realized_pnl", tt.realized, &pnl.RealizedPnL)
+			assertFloat(t, "net_pnl", tt.realized-1.5, &pnl.NetPnL)
+			assertFloat(t, "net_position", 1, &pnl.NetPosition)
+			assertFloat(t, "avg_entry_price", tt.entry, pnl.AvgEntryPrice)
+		})
+	}
+}
+
+func TestComputePnL_ShortAndFlip(t *testing.T) {
+	orders := []*model.HistoryOrder{
+		fill(0, "mm", "sell", 2, 100, 0),
+		// Covers the short at a loss of 5 per unit and flips long 1 at 105.
+		fill(1, "mm", "buy", 3, 105, 0),
+		fill(2, "mm", "sell", 1, 107, 0),
+	}
+
+	pnl := ComputePnL(orders, CostFIFO, GroupByClient)[0]
+	assertFloat(t, "realized_pnl", -10+2, &pnl.RealizedPnL)
+	if pnl.NetPosition != 0 || pnl.AvgEntryPrice != nil {
+		t.Errorf("expected a flat position, but got %v at %v", pnl.NetPosition, pnl.AvgEntryPrice)
+	}
+}
+
+func TestComputePnL_GroupByAlgorithm(t *testing.T) {
+	other := fill(1, "vwap", "buy", 1, 100, 0)
+	other.ClientName = "other"
+	orders := []*model.HistoryOrder{
+		fill(0, "twap", "buy", 1, 100, 0),
+		other,
+		fill(2, "vwap", "sell", 1, 90, 0),
+	}
+
+	groups := ComputePnL(orders, CostFIFO, GroupByAlgorithm)
+	if len(groups) != 2 || groups[0].AlgorithmNamePlaced != "twap" || groups[1].AlgorithmNamePlaced != "vwap" {
+		t.Fatalf("expected twap and vwap groups, but got %+v", groups)
+	}
+	if groups[0].ClientName != "" || groups[0].NetPosition != 1 {
+		t.Errorf("unexpected twap group %+v", groups[0])
+	}
+	// The vwap fills of both clients share one position.
+	assertFloat(t, "vwap realized_pnl", -10, &groups[1].RealizedPnL)
+}
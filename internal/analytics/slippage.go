@@ -0,0 +1,191 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// Dimensions that slippage can be grouped by.
+const (
+	DimensionAlgorithm = "algorithm"
+	DimensionTypeOrder = "type_order"
+	DimensionPair      = "pair"
+)
+
+// SlippageDimensions are the accepted grouping dimensions, in the order
+// groups are sorted by.
+var SlippageDimensions = []string{DimensionAlgorithm, DimensionTypeOrder, DimensionPair}
+
+// OrderSlippage is the execution price of an order compared with the top of
+// the book recorded when it was placed. Positive slippage is adverse: a buy
+// above the reference or a sell below it.
+type OrderSlippage struct {
+	Order *model.HistoryOrder `json:"order"`
+	// TouchBps is the slippage versus the best price on the other side:
+	// lowest_sell_prc for buys and highest_buy_prc for sells.
+	TouchBps float64 `json:"touch_bps"`
+	// MidBps is the slippage versus the mid of the two.
+	MidBps float64 `json:"mid_bps"`
+}
+
+// ComputeOrderSlippage compares order with its recorded top of book. It
+// reports false when the order has no usable top of book: a missing side or
+// a crossed book.
+func ComputeOrderSlippage(order *model.HistoryOrder) (OrderSlippage, bool) {
+	ask, bid := order.LowestSellPrc, order.HighestBuyPrc
+	if ask <= 0 || bid <= 0 || ask < bid {
+		return OrderSlippage{}, false
+	}
+	mid := (ask + bid) / 2
+	slippage := OrderSlippage{Order: order}
+	if order.Side == "sell" {
+		slippage.TouchBps = (bid - order.Price) / bid * 1e4
+		slippage.MidBps = (mid - order.Price) / mid * 1e4
+	} else {
+		slippage.TouchBps = (order.Price - ask) / ask * 1e4
+		slippage.MidBps = (order.Price - mid) / mid * 1e4
+	}
+	return slippage, true
+}
+
+// Distribution summarizes slippage values in basis points. Percentiles are
+// interpolated linearly between the closest ranks.
+type Distribution struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// SlippageGroup aggregates the orders sharing the values of the grouping
+// dimensions. Dimensions that are not grouped by are omitted.
+type SlippageGroup struct {
+	AlgorithmNamePlaced string `json:"algorithm_name_placed,omitempty"`
+	TypeOrder           string `json:"type_order,omitempty"`
+	Pair                string `json:"pair,omitempty"`
+
+	Orders int `json:"orders"`
+	// Notional is the sum of base_qty * price, in the quote currency.
+	Notional float64      `json:"notional"`
+	TouchBps Distribution `json:"touch_bps"`
+	MidBps   Distribution `json:"mid_bps"`
+	// Outliers counts the orders of the group beyond the outlier threshold.
+	Outliers int `json:"outliers"`
+}
+
+// SlippageReport is the execution quality of a set of orders.
+type SlippageReport struct {
+	Groups []*SlippageGroup `json:"groups"`
+	// Outliers are the orders whose touch slippage exceeds the threshold in
+	// either direction, largest first.
+	Outliers []OrderSlippage `json:"outliers"`
+	// Skipped counts the orders without a usable top of book.
+	Skipped int `json:"skipped"`
+}
+
+// ComputeSlippage groups the slippage of orders by dimensions, a subset of
+// SlippageDimensions, and flags orders whose touch slippage is beyond
+// outlierBps. At most maxOutliers outliers are listed; the counts of the
+// groups include all of them.
+func ComputeSlippage(orders []*model.HistoryOrder, dimensions []string, outlierBps float64, maxOutliers int) *SlippageReport {
+	grouped := make(map[string]bool, len(dimensions))
+	for _, dimension := range dimensions {
+		grouped[dimension] = true
+	}
+
+	report := &SlippageReport{Groups: []*SlippageGroup{}, Outliers: []OrderSlippage{}}
+	// The group key is the SlippageGroup with only its key fields set.
+	groups := make(map[SlippageGroup]*SlippageGroup)
+	touch := make(map[*SlippageGroup][]float64)
+	mid := make(map[*SlippageGroup][]float64)
+	for _, order := range orders {
+		slippage, ok := ComputeOrderSlippage(order)
+		if !ok {
+			report.Skipped++
+			continue
+		}
+
+		var key SlippageGroup
+		if grouped[DimensionAlgorithm] {
+			key.AlgorithmNamePlaced = order.AlgorithmNamePlaced
+		}
+		if grouped[DimensionTypeOrder] {
+			key.TypeOrder = order.TypeOrder
+		}
+		if grouped[DimensionPair] {
+			key.Pair = order.Pair
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &key
+			groups[key] = group
+			report.Groups = append(report.Groups, group)
+		}
+
+		group.Orders++
+		group.Notional += order.BaseQty * order.Price
+		touch[group] = append(touch[group], slippage.TouchBps)
+		mid[group] = append(mid[group], slippage.MidBps)
+		if math.Abs(slippage.TouchBps) > outlierBps {
+			group.Outliers++
+			report.Outliers = append(report.Outliers, slippage)
+		}
+	}
+
+	for _, group := range report.Groups {
+		group.TouchBps = distribution(touch[group])
+		group.MidBps = distribution(mid[group])
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.AlgorithmNamePlaced != b.AlgorithmNamePlaced {
+			return a.AlgorithmNamePlaced < b.AlgorithmNamePlaced
+		}
+		if a.TypeOrder != b.TypeOrder {
+			return a.TypeOrder < b.TypeOrder
+		}
+		return a.Pair < b.Pair
+	})
+	sort.SliceStable(report.Outliers, func(i, j int) bool {
+		return math.Abs(report.Outliers[i].TouchBps) > math.Abs(report.Outliers[j].TouchBps)
+	})
+	if len(report.Outliers) > maxOutliers {
+		report.Outliers = report.Outliers[:maxOutliers]
+	}
+	return report
+}
+
+// distribution summarizes values, which it sorts in place.
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(values)
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return Distribution{
+		Mean: sum / float64(len(values)),
+		Min:  values[0],
+		P50:  percentile(values, 0.5),
+		P90:  percentile(values, 0.9),
+		P99:  percentile(values, 0.99),
+		Max:  values[len(values)-1],
+	}
+}
+
+// percentile returns the p-th quantile of sorted, interpolating linearly
+// between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func placed(id int64, algorithm, side string, price, ask, bid float64) *model.HistoryOrder {
+	return &model.HistoryOrder{
+		ID:                  id,
+		Pair:                "BTC/USDT",
+		Side:                side,
+		TypeOrder:           "limit",
+		BaseQty:             1,
+		Price:               price,
+		AlgorithmNamePlaced: algorithm,
+		LowestSellPrc:       ask,
+		HighestBuyPrc:       bid,
+	}
+}
+
+func TestComputeOrderSlippage(t *testing.T) {
+	tests := []struct {
+		name  string
+		order *model.HistoryOrder
+		touch float64
+		mid   float64
+	}{
+		{"buy above the ask", placed(1, "twap", "buy", 100.1, 100, 99.8), 10, 0.2 / 99.9 * 1e4},
+		{"sell below the bid", placed(2, "twap", "sell", 99.7, 100, 99.8), 0.1 / 99.8 * 1e4, 0.2 / 99.9 * 1e4},
+		{"sell above the bid", placed(3, "twap", "sell", 100, 100, 99.8), -0.2 / 99.8 * 1e4, -0.1 / 99.9 * 1e4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slippage, ok := ComputeOrderSlippage(tt.order)
+			if !ok {
+				t.Fatal("expected a usable top of book")
+			}
+			assertFloat(t, "touch_bps", tt.touch, &slippage.TouchBps)
+			assertFloat(t, "mid_bps", tt.mid, &slippage.MidBps)
+		})
+	}
+
+	for _, order := range []*model.HistoryOrder{
+		placed(4, "twap", "buy", 100, 0, 99.8),
+		placed(5, "twap", "buy", 100, 99, 99.8),
+	} {
+		if _, ok := ComputeOrderSlippage(order); ok {
+			t.Errorf("expected order with ask %v and bid %v to be skipped", order.LowestSellPrc, order.HighestBuyPrc)
+		}
+	}
+}
+
+func TestComputeSlippage(t *testing.T) {
+	market := placed(3, "sniper", "buy", 100, 100, 99)
+	market.TypeOrder = "market"
+	outlier := placed(5, "sniper", "buy", 101, 100, 99.9)
+	outlier.TypeOrder = "market"
+	orders := []*model.HistoryOrder{
+		placed(1, "twap", "buy", 100.1, 100, 99.8),
+		placed(2, "twap", "buy", 100.3, 100, 99.8),
+		market,
+		placed(4, "twap", "buy", 100, 0, 0),
+		outlier,
+	}
+
+	report := ComputeSlippage(orders, []string{DimensionAlgorithm}, 50, 10)
+	if report.Skipped != 1 {
+		t.Errorf("expected 1 skipped order, but got %d", report.Skipped)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].AlgorithmNamePlaced != "sniper" || report.Groups[1].AlgorithmNamePlaced != "twap" {
+		t.Fatalf("expected sniper and twap groups, but got %+v", report.Groups)
+	}
+	sniper, twap := report.Groups[0], report.Groups[1]
+	if sniper.TypeOrder != "" || sniper.Pair != "" || sniper.Orders != 2 || sniper.Outliers != 1 {
+		t.Errorf("unexpected sniper group %+v", sniper)
+	}
+	assertFloat(t, "sniper touch max", 100, &sniper.TouchBps.Max)
+	assertFloat(t, "sniper touch p90", 90, &sniper.TouchBps.P90)
+	assertFloat(t, "twap touch mean", 20, &twap.TouchBps.Mean)
+	assertFloat(t, "twap touch p50", 20, &twap.TouchBps.P50)
+	assertFloat(t, "twap notional", 200.4, &twap.Notional)
+	if len(report.Outliers) != 1 || report.Outliers[0].Order != outlier {
+		t.Errorf("expected order 5 to be the only outlier, but got %+v", report.Outliers)
+	}
+
+	report = ComputeSlippage(orders, []string{DimensionAlgorithm, DimensionTypeOrder}, 5, 1)
+	if len(report.Groups) != 2 || report.Groups[0].TypeOrder != "market" {
+		t.Errorf("expected groups by algorithm and type, but got %+v", report.Groups)
+	}
+	// Three orders exceed 5 bps, but only the largest is listed.
+	if report.Groups[0].Outliers+report.Groups[1].Outliers != 3 || len(report.Outliers) != 1 || report.Outliers[0].Order != outlier {
+		t.Errorf("expected 3 outliers with only the largest listed, but got %+v", report.Outliers)
+	}
+}
@@ -0,0 +1,158 @@
+// Package chclient opens ClickHouse connections configured by config.ClickHouse.
+package chclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+)
+
+// Compression methods and connection strategies accepted in config.ClickHouse.
+var (
+	compressions = map[string]clickhouse.CompressionMethod{
+		"":     clickhouse.CompressionNone,
+		"none": clickhouse.CompressionNone,
+		"lz4":  clickhouse.CompressionLZ4,
+		"zstd": clickhouse.CompressionZSTD,
+	}
+	connOpenStrategies = map[string]clickhouse.ConnOpenStrategy{
+		"":            clickhouse.ConnOpenRoundRobin,
+		"in_order":    clickhouse.ConnOpenInOrder,
+		"round_robin": clickhouse.ConnOpenRoundRobin,
+		"random":      clickhouse.ConnOpenRandom,
+	}
+)
+
+// Open connects with the credentials of cfg.
+func Open(cfg config.ClickHouse) (driver.Conn, error) {
+	options, err := Options(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return clickhouse.Open(options)
+}
+
+// Options translates cfg into driver options. Callers that connect as
+// another user, such as the migration bootstrap, replace Auth.
+func Options(cfg config.ClickHouse) (*clickhouse.Options, error) {
+	compression, ok := compressions[cfg.Compression]
+	if !ok {
+		return nil, fmt.Errorf("clickhouse.compression: unknown method %q, expected none, lz4 or zstd", cfg.Compression)
+	}
+	strategy, ok := connOpenStrategies[cfg.ConnOpenStrategy]
+	if !ok {
+		return nil, fmt.Errorf("clickhouse.conn_open_strategy: unknown strategy %q, expected in_order, round_robin or random", cfg.ConnOpenStrategy)
+	}
+
+	options := &clickhouse.Options{
+		Addr: Addresses(cfg),
+		Auth: clickhouse.Auth{
+			Database: cfg.DB,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		},
+		Compression:      &clickhouse.Compression{Method: compression},
+		ConnOpenStrategy: strategy,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		MaxOpenConns:     cfg.MaxOpenConns,
+		MaxIdleConns:     cfg.MaxIdleConns,
+		ConnMaxLifetime:  cfg.ConnMaxLifetime,
+		Debug:            cfg.Debug,
+	}
+	if cfg.Debug {
+		options.Debugf = func(format string, v ...any) {
+			slog.Debug(fmt.Sprintf(format, v...), "component", "clickhouse")
+		}
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := tlsConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options.TLS = tlsConfig
+	}
+	if cfg.WriteTimeout > 0 {
+		options.DialContext = dialWithWriteTimeout(options.DialTimeout, options.TLS, cfg.WriteTimeout)
+	}
+
+	return options, nil
+}
+
+// Addresses returns the addresses of cfg, falling back to Host and Port.
+func Addresses(cfg config.ClickHouse) []string {
+	if len(cfg.Addresses) > 0 {
+		return cfg.Addresses
+	}
+	return []string{net.JoinHostPort(cfg.Host, cfg.Port)}
+}
+
+func tlsConfig(cfg config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// dialWithWriteTimeout dials like the driver does, with tlsConfig when set,
+// and bounds every write on the connection by timeout. The driver only has
+// a read timeout of its own.
+func dialWithWriteTimeout(dialTimeout time.Duration, tlsConfig *tls.Config, timeout time.Duration) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		var (
+			conn net.Conn
+			err  error
+		)
+		if tlsConfig != nil {
+			conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+		} else {
+			conn, err = dialer.DialContext(ctx, "tcp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &writeTimeoutConn{Conn: conn, timeout: timeout}, nil
+	}
+}
+
+type writeTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *writeTimeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,89 @@
+package chclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+)
+
+func TestOptions(t *testing.T) {
+	options, err := Options(config.ClickHouse{
+		Host:             "localhost",
+		Port:             "9000",
+		DB:               "stats",
+		Username:         "stats",
+		Compression:      "zstd",
+		ConnOpenStrategy: "in_order",
+		MaxOpenConns:     20,
+		MaxIdleConns:     10,
+		ConnMaxLifetime:  time.Hour,
+		ReadTimeout:      time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to build options: %v", err)
+	}
+	if len(options.Addr) != 1 || options.Addr[0] != "localhost:9000" {
+		t.Errorf("expected the host and port as the only address, but got %v", options.Addr)
+	}
+	if options.Compression.Method != clickhouse.CompressionZSTD || options.ConnOpenStrategy != clickhouse.ConnOpenInOrder {
+		t.Errorf("unexpected compression %v or strategy %v", options.Compression.Method, options.ConnOpenStrategy)
+	}
+	if options.MaxOpenConns != 20 || options.MaxIdleConns != 10 || options.ConnMaxLifetime != time.Hour || options.ReadTimeout != time.Minute {
+		t.Errorf("unexpected pool options: %+v", options)
+	}
+	if options.Auth.Database != "stats" || options.TLS != nil || options.DialContext != nil {
+		t.Errorf("unexpected options: %+v", options)
+	}
+
+	options, err = Options(config.ClickHouse{Host: "ignored", Addresses: []string{"ch-1:9440", "ch-2:9440"}})
+	if err != nil {
+		t.Fatalf("failed to build options: %v", err)
+	}
+	if len(options.Addr) != 2 || options.Addr[1] != "ch-2:9440" {
+		t.Errorf("expected the replica addresses, but got %v", options.Addr)
+	}
+}
+
+func TestOptions_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  config.ClickHouse
+		want string
+	}{
+		{config.ClickHouse{Compression: "gzip"}, "clickhouse.compression"},
+		{config.ClickHouse{ConnOpenStrategy: "fastest"}, "clickhouse.conn_open_strategy"},
+		{config.ClickHouse{TLS: config.TLS{Enabled: true, CAFile: "/nonexistent/ca.pem"}}, "CA file"},
+	} {
+		if _, err := Options(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected an error about %s, but got %v", tt.want, err)
+		}
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	options, err := Options(config.ClickHouse{Addresses: []string{l.Addr().String()}, DialTimeout: time.Second, WriteTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("failed to build options: %v", err)
+	}
+	if options.DialContext == nil {
+		t.Fatal("expected a dialer applying the write timeout")
+	}
+	conn, err := options.DialContext(context.Background(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*writeTimeoutConn); !ok {
+		t.Errorf("expected a connection with a write timeout, but got %T", conn)
+	}
+}
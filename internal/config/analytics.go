@@ -0,0 +1,8 @@
+package config
+
+// Analytics configures the endpoints that analyze stored orders.
+type Analytics struct {
+	// SlippageOutlierBps is the slippage versus the touch, in basis points,
+	// beyond which the execution-quality report flags an order.
+	SlippageOutlierBps float64 `yaml:"slippage_outlier_bps"`
+}
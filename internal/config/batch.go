@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// Batch configures the asynchronous writer used for order history inserts.
+type Batch struct {
+	Enabled        bool          `yaml:"enabled"`
+	Size           int           `yaml:"size"`
+	FlushInterval  time.Duration `yaml:"flush_interval"`
+	BufferSize     int           `yaml:"buffer_size"`
+	EnqueueTimeout time.Duration `yaml:"enqueue_timeout"`
+	FlushTimeout   time.Duration `yaml:"flush_timeout"`
+	MaxRetries     int           `yaml:"max_retries"`
+}
@@ -1,9 +1,43 @@
 package config
 
+import "time"
+
 type ClickHouse struct {
-	Host 		string `yaml:"host"`
-	Port 		string `yaml:"port"`
-	DB   		string `yaml:"db"`
-	Username	string `yaml:"username"`
-	Password	string `yaml:"password"`
-}
\ No newline at end of file
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	// Addresses lists the host:port of every replica to connect to. When set,
+	// Host and Port are ignored.
+	Addresses []string `yaml:"addresses"`
+	DB        string   `yaml:"db"`
+	// InstanceID goes into every id this instance hands out. Instances writing
+	// to the same database need distinct ids between 0 and 1023.
+	InstanceID int    `yaml:"instance_id"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	TLS        TLS    `yaml:"tls"`
+	// Compression is none, lz4 or zstd.
+	Compression string `yaml:"compression"`
+	// ConnOpenStrategy picks the address of a new connection: in_order,
+	// round_robin or random.
+	ConnOpenStrategy string        `yaml:"conn_open_strategy"`
+	DialTimeout      time.Duration `yaml:"dial_timeout"`
+	ReadTimeout      time.Duration `yaml:"read_timeout"`
+	WriteTimeout     time.Duration `yaml:"write_timeout"`
+	MaxOpenConns     int           `yaml:"max_open_conns"`
+	MaxIdleConns     int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime"`
+	// Debug logs the driver's protocol messages at debug level.
+	Debug bool  `yaml:"debug"`
+	Batch Batch `yaml:"batch"`
+}
+
+// TLS configures encrypted connections to ClickHouse. CertFile and KeyFile
+// enable client certificate authentication.
+type TLS struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
@@ -0,0 +1,6 @@
+package config
+
+type Clients struct {
+	// RequireRegistration rejects orders of clients missing from the registry.
+	RequireRegistration bool `yaml:"require_registration"`
+}
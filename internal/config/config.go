@@ -1,6 +1,13 @@
 package config
 
 type Config struct {
-	Server		Server		`yaml:"server"`
-	ClickHouse	ClickHouse	`yaml:"clickhouse"`
-}
\ No newline at end of file
+	Server     Server     `yaml:"server"`
+	Storage    Storage    `yaml:"storage"`
+	ClickHouse ClickHouse `yaml:"clickhouse"`
+	Postgres   Postgres   `yaml:"postgres"`
+	SQLite     SQLite     `yaml:"sqlite"`
+	Migrate    Migrate    `yaml:"migrate"`
+	Clients    Clients    `yaml:"clients"`
+	Analytics  Analytics  `yaml:"analytics"`
+	Log        Log        `yaml:"log"`
+}
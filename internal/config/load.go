@@ -0,0 +1,320 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix starts the name of every environment override, as in
+	// STATS_CLICKHOUSE_PASSWORD.
+	EnvPrefix = "STATS"
+	// EnvConfig names the configuration file when --config is not given.
+	EnvConfig = EnvPrefix + "_CONFIG"
+	// fileSuffix marks an override whose value is read from the named file,
+	// as in STATS_CLICKHOUSE_PASSWORD_FILE=/run/secrets/clickhouse_password.
+	fileSuffix = "_FILE"
+
+	defaultPath = "config/config.yaml"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// DefaultPath returns the configuration file used when no --config flag is
+// given: $STATS_CONFIG, or config/config.yaml.
+func DefaultPath() string {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path
+	}
+	return defaultPath
+}
+
+// Default returns the configuration used for keys that are neither in the
+// file nor in the environment.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Storage: Storage{
+			Driver: "clickhouse",
+		},
+		ClickHouse: ClickHouse{
+			Host:             "localhost",
+			Port:             "9000",
+			ConnOpenStrategy: "round_robin",
+			DialTimeout:      30 * time.Second,
+		},
+		SQLite: SQLite{
+			BusyTimeout: 5 * time.Second,
+		},
+		Analytics: Analytics{
+			SlippageOutlierBps: 50,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Load reads the YAML file at path over Default, applies the environment
+// overrides and validates the result. Every field can be overridden by the
+// variable named after its YAML path, upper-cased and prefixed with STATS_:
+// clickhouse.batch.flush_interval becomes STATS_CLICKHOUSE_BATCH_FLUSH_INTERVAL.
+// The same name with a _FILE suffix reads the value from a file, for secrets
+// mounted by the orchestrator.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix, os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// applyEnv sets the fields of the struct v from the variables named prefix
+// followed by their YAML path.
+func applyEnv(v reflect.Value, prefix string, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name, lookupEnv); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		value, ok := lookupEnv(name)
+		if !ok {
+			file, fileOK := lookupEnv(name + fileSuffix)
+			if !fileOK {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", name, fileSuffix, err))
+				continue
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setField parses value into field. Slices are written as comma-separated
+// values and maps as comma-separated key=value pairs, as in
+// /bulk/order-history=5m,/candles=30s.
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values).Convert(field.Type()))
+	case field.Kind() == reflect.Map && field.Type().Key().Kind() == reflect.String:
+		m := reflect.MakeMap(field.Type())
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, elem, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", pair)
+			}
+			v := reflect.New(field.Type().Elem()).Elem()
+			if err := setField(v, strings.TrimSpace(elem)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), v)
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate reports every invalid field, named by its YAML path.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if !validPort(c.Server.Port) {
+		invalid("server.port", "%q is not a port number", c.Server.Port)
+	}
+	for field, d := range map[string]time.Duration{
+		"server.shutdown_timeout":          c.Server.ShutdownTimeout,
+		"server.request_timeout":           c.Server.RequestTimeout,
+		"clickhouse.dial_timeout":          c.ClickHouse.DialTimeout,
+		"clickhouse.read_timeout":          c.ClickHouse.ReadTimeout,
+		"clickhouse.write_timeout":         c.ClickHouse.WriteTimeout,
+		"clickhouse.conn_max_lifetime":     c.ClickHouse.ConnMaxLifetime,
+		"postgres.conn_max_lifetime":       c.Postgres.ConnMaxLifetime,
+		"sqlite.busy_timeout":              c.SQLite.BusyTimeout,
+		"clickhouse.batch.flush_interval":  c.ClickHouse.Batch.FlushInterval,
+		"clickhouse.batch.enqueue_timeout": c.ClickHouse.Batch.EnqueueTimeout,
+		"clickhouse.batch.flush_timeout":   c.ClickHouse.Batch.FlushTimeout,
+	} {
+		if d < 0 {
+			invalid(field, "must not be negative, got %v", d)
+		}
+	}
+	for route, d := range c.Server.RouteTimeouts {
+		if !strings.HasPrefix(route, "/") {
+			invalid("server.route_timeouts", "route %q must start with /", route)
+		}
+		if d < 0 {
+			invalid("server.route_timeouts", "timeout of %s must not be negative, got %v", route, d)
+		}
+	}
+	for field, n := range map[string]int{
+		"clickhouse.max_open_conns":    c.ClickHouse.MaxOpenConns,
+		"clickhouse.max_idle_conns":    c.ClickHouse.MaxIdleConns,
+		"clickhouse.batch.size":        c.ClickHouse.Batch.Size,
+		"clickhouse.batch.buffer_size": c.ClickHouse.Batch.BufferSize,
+		"clickhouse.batch.max_retries": c.ClickHouse.Batch.MaxRetries,
+		"postgres.max_open_conns":      c.Postgres.MaxOpenConns,
+		"postgres.max_idle_conns":      c.Postgres.MaxIdleConns,
+	} {
+		if n < 0 {
+			invalid(field, "must not be negative, got %d", n)
+		}
+	}
+	if c.ClickHouse.InstanceID < 0 || c.ClickHouse.InstanceID > 1023 {
+		invalid("clickhouse.instance_id", "must be between 0 and 1023, got %d", c.ClickHouse.InstanceID)
+	}
+	if c.ClickHouse.MaxOpenConns > 0 && c.ClickHouse.MaxIdleConns > c.ClickHouse.MaxOpenConns {
+		invalid("clickhouse.max_idle_conns", "must not exceed max_open_conns (%d), got %d", c.ClickHouse.MaxOpenConns, c.ClickHouse.MaxIdleConns)
+	}
+	switch c.ClickHouse.Compression {
+	case "", "none", "lz4", "zstd":
+	default:
+		invalid("clickhouse.compression", "%q is not one of none, lz4 or zstd", c.ClickHouse.Compression)
+	}
+	switch c.ClickHouse.ConnOpenStrategy {
+	case "", "in_order", "round_robin", "random":
+	default:
+		invalid("clickhouse.conn_open_strategy", "%q is not one of in_order, round_robin or random", c.ClickHouse.ConnOpenStrategy)
+	}
+	if (c.ClickHouse.TLS.CertFile == "") != (c.ClickHouse.TLS.KeyFile == "") {
+		invalid("clickhouse.tls", "cert_file and key_file must be set together")
+	}
+
+	if c.Storage.Driver == "" || c.Storage.Driver == "clickhouse" {
+		if len(c.ClickHouse.Addresses) == 0 {
+			if c.ClickHouse.Host == "" {
+				invalid("clickhouse.host", "is required")
+			}
+			if !validPort(c.ClickHouse.Port) {
+				invalid("clickhouse.port", "%q is not a port number", c.ClickHouse.Port)
+			}
+		}
+		for _, addr := range c.ClickHouse.Addresses {
+			if host, port, err := net.SplitHostPort(addr); err != nil || host == "" || !validPort(port) {
+				invalid("clickhouse.addresses", "%q is not a host:port address", addr)
+			}
+		}
+		if c.ClickHouse.DB == "" {
+			invalid("clickhouse.db", "is required")
+		}
+	}
+
+	if c.Storage.Driver == "postgres" && c.Postgres.DSN == "" {
+		invalid("postgres.dsn", "is required by the postgres driver")
+	}
+	if c.Storage.Driver == "sqlite" && c.SQLite.Path == "" {
+		invalid("sqlite.path", "is required by the sqlite driver")
+	}
+	if c.Analytics.SlippageOutlierBps <= 0 {
+		invalid("analytics.slippage_outlier_bps", "must be positive, got %v", c.Analytics.SlippageOutlierBps)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level", "%q is not one of debug, info, warn or error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		invalid("log.format", "%q is not one of text or json", c.Log.Format)
+	}
+
+	// Maps are iterated in random order; sort so the message is stable.
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errors.Join(errs...)
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad_RepositoryConfig(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config", "config.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.ClickHouse.DB != "my_database" || cfg.Server.RouteTimeouts["/bulk/order-history"] != 5*time.Minute {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.ClickHouse.Password != "" || cfg.Migrate.AdminUsername != "" || cfg.Migrate.AdminPassword != "" {
+		t.Error("expected no credentials in the committed config")
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "clickhouse:\n  db: stats\n"))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Server.Port != "8080" || cfg.Server.ShutdownTimeout != 30*time.Second || cfg.ClickHouse.Host != "localhost" {
+		t.Errorf("expected defaults to be applied, but got %+v", cfg)
+	}
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+	t.Setenv("STATS_SERVER_PORT", "9090")
+	t.Setenv("STATS_SERVER_ROUTE_TIMEOUTS", "/candles=30s, /bulk/order-history=0")
+	t.Setenv("STATS_CLICKHOUSE_BATCH_ENABLED", "true")
+	t.Setenv("STATS_CLICKHOUSE_BATCH_FLUSH_INTERVAL", "250ms")
+	t.Setenv("STATS_CLICKHOUSE_PASSWORD_FILE", secret)
+	t.Setenv("STATS_CLICKHOUSE_ADDRESSES", "ch-1:9440, ch-2:9440")
+	t.Setenv("STATS_ANALYTICS_SLIPPAGE_OUTLIER_BPS", "12.5")
+
+	cfg, err := Load(writeConfig(t, "server:\n  port: \"8080\"\nclickhouse:\n  db: stats\n  password: committed\n"))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Server.Port != "9090" {
+		t.Errorf("expected port 9090, but got %q", cfg.Server.Port)
+	}
+	if cfg.ClickHouse.Password != "s3cret" {
+		t.Errorf("expected the password from the file, but got %q", cfg.ClickHouse.Password)
+	}
+	if cfg.Analytics.SlippageOutlierBps != 12.5 {
+		t.Errorf("expected outlier threshold 12.5, but got %v", cfg.Analytics.SlippageOutlierBps)
+	}
+	if len(cfg.ClickHouse.Addresses) != 2 || cfg.ClickHouse.Addresses[1] != "ch-2:9440" {
+		t.Errorf("expected two addresses, but got %v", cfg.ClickHouse.Addresses)
+	}
+	if !cfg.ClickHouse.Batch.Enabled || cfg.ClickHouse.Batch.FlushInterval != 250*time.Millisecond {
+		t.Errorf("unexpected batch config: %+v", cfg.ClickHouse.Batch)
+	}
+	want := map[string]time.Duration{"/candles": 30 * time.Second, "/bulk/order-history": 0}
+	if len(cfg.Server.RouteTimeouts) != len(want) {
+		t.Errorf("expected route timeouts %v, but got %v", want, cfg.Server.RouteTimeouts)
+	}
+	for route, timeout := range want {
+		if got, ok := cfg.Server.RouteTimeouts[route]; !ok || got != timeout {
+			t.Errorf("expected timeout %v for %s, but got %v", timeout, route, got)
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    []string
+	}{
+		{
+			name:    "unknown field",
+			content: "clickhouse:\n  db: stats\n  passwrod: x\n",
+			want:    []string{"passwrod"},
+		},
+		{
+			name:    "invalid values",
+			content: "server:\n  port: http\n  request_timeout: -1s\nclickhouse:\n  db: \"\"\nlog:\n  level: verbose\n  format: xml\n",
+			want:    []string{"server.port", "server.request_timeout", "clickhouse.db", "log.level", "log.format"},
+		},
+		{
+			name:    "invalid clickhouse options",
+			content: "clickhouse:\n  db: stats\n  instance_id: 1024\n  addresses: [ch-1]\n  compression: gzip\n  conn_open_strategy: fastest\n  max_open_conns: 5\n  max_idle_conns: 10\n  tls:\n    cert_file: client.pem\n",
+			want:    []string{"clickhouse.instance_id", "clickhouse.addresses", "clickhouse.compression", "clickhouse.conn_open_strategy", "clickhouse.max_idle_conns", "clickhouse.tls"},
+		},
+		{
+			name:    "postgres without dsn",
+			content: "storage:\n  driver: postgres\npostgres:\n  max_open_conns: -1\n",
+			want:    []string{"postgres.dsn", "postgres.max_open_conns"},
+		},
+		{
+			name:    "sqlite without path",
+			content: "storage:\n  driver: sqlite\nsqlite:\n  busy_timeout: -1s\n",
+			want:    []string{"sqlite.path", "sqlite.busy_timeout"},
+		},
+		{
+			name:    "invalid analytics",
+			content: "clickhouse:\n  db: stats\nanalytics:\n  slippage_outlier_bps: 0\n",
+			want:    []string{"analytics.slippage_outlier_bps"},
+		},
+		{
+			name:    "invalid override",
+			content: "clickhouse:\n  db: stats\n",
+			env:     map[string]string{"STATS_CLICKHOUSE_BATCH_SIZE": "many"},
+			want:    []string{"STATS_CLICKHOUSE_BATCH_SIZE"},
+		},
+		{
+			name:    "missing secret file",
+			content: "clickhouse:\n  db: stats\n",
+			env:     map[string]string{"STATS_CLICKHOUSE_PASSWORD_FILE": "/nonexistent/password"},
+			want:    []string{"STATS_CLICKHOUSE_PASSWORD_FILE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(writeConfig(t, tt.content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to mention %s, but got: %v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package config
+
+type Migrate struct {
+	Dir           string `yaml:"dir"`
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+}
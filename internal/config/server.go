@@ -1,6 +1,15 @@
 package config
 
+import "time"
+
 type Server struct {
-	Host string 	`yaml:"host"`
-	Port string 	`yaml:"port"`
-}
\ No newline at end of file
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	// ShutdownTimeout bounds the whole graceful shutdown: draining requests,
+	// flushing pending writes and closing the storage.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// RequestTimeout is the deadline of every request unless RouteTimeouts
+	// has an entry for its route. Zero means no deadline.
+	RequestTimeout time.Duration            `yaml:"request_timeout"`
+	RouteTimeouts  map[string]time.Duration `yaml:"route_timeouts"`
+}
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// SQLite configures the sqlite storage driver.
+type SQLite struct {
+	// Path is the database file, created with its schema if it does not exist.
+	Path string `yaml:"path"`
+	// BusyTimeout is how long a write waits for another one to finish.
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+}
@@ -0,0 +1,5 @@
+package config
+
+type Storage struct {
+	Driver string `yaml:"driver"`
+}
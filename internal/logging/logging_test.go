@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.Log{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	logger.DebugContext(context.Background(), "hidden")
+	logger.With("component", "test").InfoContext(WithRequestID(context.Background(), "req-1"), "shown")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record, but got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "shown" || record["request_id"] != "req-1" || record["component"] != "test" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []config.Log{
+		{Level: "verbose", Format: "text"},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := New(cfg, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package metrics defines the Prometheus metrics of the service.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "statistics"
+
+// latencyBuckets are in seconds and have a bound at the 200 ms latency SLO.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.3, 0.5, 1, 2.5, 5, 10}
+
+// Metrics holds the collectors of the service in their own registry. A nil
+// *Metrics is valid and records nothing, which keeps tests and tools that do
+// not expose metrics free of them.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	httpInFlight        *prometheus.GaugeVec
+	storageDuration     *prometheus.HistogramVec
+	storageErrors       *prometheus.CounterVec
+	batchSize           prometheus.Histogram
+	flushErrors         prometheus.Counter
+	ordersDropped       prometheus.Counter
+	rowsInserted        *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and response status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and response status.",
+			Buckets:   latencyBuckets,
+		}, []string{"route", "method", "status"}),
+		httpInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served by route pattern.",
+		}, []string{"route"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_call_duration_seconds",
+			Help:      "Storage call latency by IStatistics method.",
+			Buckets:   latencyBuckets,
+		}, []string{"method"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_errors_total",
+			Help:      "Failed storage calls by IStatistics method.",
+		}, []string{"method"}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "history_batch_size",
+			Help:      "Orders written per flush of the history batch writer.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		flushErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "history_flush_errors_total",
+			Help:      "Failed flushes of the history batch writer.",
+		}),
+		ordersDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "history_orders_dropped_total",
+			Help:      "Orders accepted by the history batch writer and dropped without being written.",
+		}),
+		rowsInserted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_inserted_total",
+			Help:      "Rows inserted into the storage by table.",
+		}, []string{"table"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.httpInFlight,
+		m.storageDuration,
+		m.storageErrors,
+		m.batchSize,
+		m.flushErrors,
+		m.ordersDropped,
+		m.rowsInserted,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RequestStarted counts a request to route as in flight. The returned
+// function records its outcome and must be called once it is served.
+func (m *Metrics) RequestStarted(route, method string) func(status int) {
+	if m == nil {
+		return func(int) {}
+	}
+	start := time.Now()
+	inFlight := m.httpInFlight.WithLabelValues(route)
+	inFlight.Inc()
+	return func(status int) {
+		inFlight.Dec()
+		code := strconv.Itoa(status)
+		m.httpRequests.WithLabelValues(route, method, code).Inc()
+		m.httpRequestDuration.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveStorageCall records the latency of a storage call and whether it failed.
+func (m *Metrics) ObserveStorageCall(method string, duration time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	m.storageDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if failed {
+		m.storageErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveBatch records the number of orders written by one flush.
+func (m *Metrics) ObserveBatch(rows int) {
+	if m == nil {
+		return
+	}
+	m.batchSize.Observe(float64(rows))
+}
+
+// FlushFailed counts a failed flush of the batch writer.
+func (m *Metrics) FlushFailed() {
+	if m == nil {
+		return
+	}
+	m.flushErrors.Inc()
+}
+
+// OrdersDropped counts orders the batch writer gave up writing.
+func (m *Metrics) OrdersDropped(orders int) {
+	if m == nil {
+		return
+	}
+	m.ordersDropped.Add(float64(orders))
+}
+
+// RowsInserted adds rows to the count of rows inserted into table.
+func (m *Metrics) RowsInserted(table string, rows int) {
+	if m == nil {
+		return
+	}
+	m.rowsInserted.WithLabelValues(table).Add(float64(rows))
+}
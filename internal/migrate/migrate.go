@@ -1,126 +1,393 @@
 package migrate
 
 import (
-    "context"
-    "fmt"
-    "io"
-    "log"
-    "os"
-    "path/filepath"
-    "strings"
-    "time"
-
-    "github.com/ClickHouse/clickhouse-go/v2"
-    "github.com/mbatimel/HW_Statistics_collection_service/internal/config"
-    "gopkg.in/yaml.v3"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/chclient"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+)
+
+const (
+	defaultDir    = "migration"
+	bootstrapFile = "initial_migration.sql"
+
+	// errAccessStorageReadonly is returned by ClickHouse for users defined
+	// in users.xml, which cannot be changed with SQL.
+	errAccessStorageReadonly = 495
 )
 
-func loadConfig() (*config.Config, error) {
-    configPath := filepath.Join("config", "config.yaml")
-    file, err := os.Open(configPath)
-    if err != nil {
-        return nil, err
-    }
-    defer file.Close()
-
-    var config config.Config
-    decoder := yaml.NewDecoder(file)
-    err = decoder.Decode(&config)
-    if err != nil {
-        return nil, err
-    }
-    return &config, nil
-}
-
-func runSQLFile(conn clickhouse.Conn, ctx context.Context, filePath string) error {
-    file, err := os.Open(filePath)
-    if err != nil {
-        return fmt.Errorf("Open migration file failed: %v", err)
-    }
-    defer file.Close()
-
-    sqlData, err := io.ReadAll(file)
-    if err != nil {
-        return fmt.Errorf("ReadAll migration file failed: %v", err)
-    }
-
-    queries := strings.Split(string(sqlData), ";")
-    for _, query := range queries {
-        query = strings.TrimSpace(query)
-        if query == "" {
-            continue
-        }
-        log.Printf("Executing query: %s", query)
-        if err := conn.Exec(ctx, query); err != nil {
-            return fmt.Errorf("Exec migration query failed: %v: %s", err, query)
-        }
-        log.Printf("Successfully executed query: %s", query)
-    }
-
-    return nil
-}
-
-
-func RunMigrations() error {
-    config, err := loadConfig()
-    if err != nil {
-        return fmt.Errorf("loadConfig() failed: %v", err)
-    }
-
-    // Connect to ClickHouse with default user to create the database and user
-    conn, err := clickhouse.Open(&clickhouse.Options{
-        Addr: []string{fmt.Sprintf("%s:%s", config.ClickHouse.Host, config.ClickHouse.Port)},
-        Auth: clickhouse.Auth{
-            Username: "default",
-            Password: "default_password",
-        },
-        DialTimeout: 5 * time.Minute,
-        ConnOpenStrategy: clickhouse.ConnOpenRoundRobin,
-    })
-    if err != nil {
-        return fmt.Errorf("failed to open ClickHouse connection: %v", err)
-    }
-
-    ctx := context.Background()
-
-    log.Println("Pinging ClickHouse with default user...")
-    if err := conn.Ping(ctx); err != nil {
-        return fmt.Errorf("failed to ping ClickHouse: %v", err)
-    }
-    log.Println("Ping successful.")
-
-    log.Println("Creating database if not exists...")
-    if err := conn.Exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", config.ClickHouse.DB)); err != nil {
-        return fmt.Errorf("Exec CREATE DATABASE failed: %v", err)
-    }
-    log.Println("Database created or already exists.")
-
-    // Connect to ClickHouse with the new user and create tables
-    conn, err = clickhouse.Open(&clickhouse.Options{
-        Addr: []string{fmt.Sprintf("%s:%s", config.ClickHouse.Host, config.ClickHouse.Port)},
-        Auth: clickhouse.Auth{
-            Database: config.ClickHouse.DB,
-            Username: config.ClickHouse.Username,
-            Password: config.ClickHouse.Password,
-        },
-        DialTimeout: 5 * time.Minute,
-        ConnOpenStrategy: clickhouse.ConnOpenRoundRobin,
-    })
-    if err != nil {
-        return fmt.Errorf("failed to open ClickHouse connection with database: %v", err)
-    }
-
-    log.Println("Pinging ClickHouse with database...")
-    if err := conn.Ping(ctx); err != nil {
-        return fmt.Errorf("failed to ping ClickHouse with database: %v", err)
-    }
-    log.Println("Ping successful with database.")
-
-    log.Println("Running migration SQL file...")
-    if err := runSQLFile(conn, ctx, filepath.Join("migration", "create_tables.sql")); err != nil {
-        return err
-    }
-    log.Println("Migration SQL file executed successfully.")
-
-    return nil
+// Migrator applies and rolls back the versioned migrations and records them
+// in the schema_migrations table of the service database.
+type Migrator struct {
+	cfg        config.Config
+	dir        string
+	migrations []Migration
+	conn       driver.Conn
+}
+
+// New loads the migration files of cfg. The connection to ClickHouse is
+// opened on first use, after Bootstrap had a chance to create the database.
+func New(cfg config.Config) (*Migrator, error) {
+	dir := cfg.Migrate.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		cfg:        cfg,
+		dir:        dir,
+		migrations: migrations,
+	}, nil
+}
+
+func (m *Migrator) Close() error {
+	if m.conn == nil {
+		return nil
+	}
+	return m.conn.Close()
+}
+
+// Bootstrap runs initial_migration.sql with the admin credentials to create
+// the database and the service user. It is skipped when no admin user is configured.
+func (m *Migrator) Bootstrap(ctx context.Context) error {
+	if m.cfg.Migrate.AdminUsername == "" {
+		slog.Info("no admin user configured, skipping bootstrap")
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.dir, bootstrapFile))
+	if err != nil {
+		return fmt.Errorf("failed to read bootstrap file: %v", err)
+	}
+	queries := bootstrapStatements(string(data), m.cfg.ClickHouse)
+
+	conn, err := m.open(m.cfg.Migrate.AdminUsername, m.cfg.Migrate.AdminPassword, "")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	slog.Info("running bootstrap", "user", m.cfg.Migrate.AdminUsername)
+	for _, query := range queries {
+		err := conn.Exec(ctx, query)
+		var exception *clickhouse.Exception
+		if errors.As(err, &exception) && exception.Code == errAccessStorageReadonly {
+			slog.Warn("skipping query for a user managed outside SQL", "error", exception.Message)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Exec bootstrap query failed: %v", err)
+		}
+	}
+	slog.Info("bootstrap finished")
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	return m.apply(ctx, planUp(m.migrations, applied, LatestVersion(m.migrations)))
+}
+
+// Down rolls back the last n applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	plan, err := planDown(m.migrations, applied, 0, n)
+	if err != nil {
+		return err
+	}
+	return m.rollback(ctx, plan)
+}
+
+// To migrates up or down so that exactly the migrations up to and including
+// version are applied.
+func (m *Migrator) To(ctx context.Context, version uint64) error {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	plan, err := planDown(m.migrations, applied, version, -1)
+	if err != nil {
+		return err
+	}
+	if err := m.rollback(ctx, plan); err != nil {
+		return err
+	}
+	return m.apply(ctx, planUp(m.migrations, applied, version))
+}
+
+// Status lists every migration with its state. Unlike the other commands it
+// reports modified and missing migrations instead of failing.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.connect(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := AppliedMigrations(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+	return status(m.migrations, applied), nil
+}
+
+func (m *Migrator) known(version uint64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare connects, makes sure schema_migrations exists and verifies the
+// checksums of the applied migrations.
+func (m *Migrator) prepare(ctx context.Context) (map[uint64]AppliedMigration, error) {
+	if err := m.connect(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := AppliedMigrations(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(m.migrations, applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, plan []Migration) error {
+	if len(plan) == 0 {
+		slog.Info("no migrations to apply")
+	}
+	for _, migration := range plan {
+		slog.Info("applying migration", "version", migration.Version, "name", migration.Name)
+		if err := m.exec(ctx, migration.Up); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		if err := m.record(ctx, migration, true); err != nil {
+			return err
+		}
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, plan []Migration) error {
+	for _, migration := range plan {
+		slog.Info("rolling back migration", "version", migration.Version, "name", migration.Name)
+		if err := m.exec(ctx, migration.Down); err != nil {
+			return fmt.Errorf("rollback of migration %d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		if err := m.record(ctx, migration, false); err != nil {
+			return err
+		}
+		slog.Info("rolled back migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+func (m *Migrator) exec(ctx context.Context, sql string) error {
+	for _, query := range splitStatements(sql) {
+		slog.Debug("executing query", "query", query)
+		if err := m.conn.Exec(ctx, query); err != nil {
+			return fmt.Errorf("Exec migration query failed: %v: %s", err, query)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) record(ctx context.Context, migration Migration, applied bool) error {
+	var appliedFlag uint8
+	if applied {
+		appliedFlag = 1
+	}
+	if err := m.conn.Exec(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, applied, changed_at) VALUES (?, ?, ?, ?, ?)",
+		migration.Version, migration.Name, migration.Checksum, appliedFlag, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) connect(ctx context.Context) error {
+	if m.conn != nil {
+		return nil
+	}
+
+	conn, err := m.open(m.cfg.ClickHouse.Username, m.cfg.ClickHouse.Password, m.cfg.ClickHouse.DB)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to ping ClickHouse with database: %v", err)
+	}
+	slog.Debug("connected to ClickHouse", "db", m.cfg.ClickHouse.DB)
+
+	if err := conn.Exec(ctx, createSchemaMigrations); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	m.conn = conn
+	return nil
+}
+
+func (m *Migrator) open(username, password, database string) (driver.Conn, error) {
+	options, err := chclient.Options(m.cfg.ClickHouse)
+	if err != nil {
+		return nil, err
+	}
+	options.Auth = clickhouse.Auth{
+		Database: database,
+		Username: username,
+		Password: password,
+	}
+	conn, err := clickhouse.Open(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ClickHouse connection: %v", err)
+	}
+	return conn, nil
+}
+
+// schema_migrations is append-only: every apply and rollback adds a row and
+// the latest row of a version is its current state.
+const createSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version UInt64,
+		name String,
+		checksum String,
+		applied UInt8,
+		changed_at DateTime64(3, 'UTC')
+	) ENGINE = MergeTree()
+	ORDER BY (version, changed_at)
+`
+
+// AppliedMigrations returns the migrations currently applied in the database
+// behind conn, keyed by version.
+func AppliedMigrations(ctx context.Context, conn driver.Conn) (map[uint64]AppliedMigration, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT version, argMax(name, changed_at), argMax(checksum, changed_at),
+			argMax(applied, changed_at), max(changed_at)
+		FROM schema_migrations
+		GROUP BY version
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]AppliedMigration)
+	for rows.Next() {
+		var (
+			state       AppliedMigration
+			appliedFlag uint8
+		)
+		if err := rows.Scan(&state.Version, &state.Name, &state.Checksum, &appliedFlag, &state.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row for schema_migrations: %v", err)
+		}
+		if appliedFlag == 1 {
+			state.Applied = true
+			applied[state.Version] = state
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over schema_migrations rows: %v", err)
+	}
+	return applied, nil
+}
+
+// bootstrapStatements splits the bootstrap template into statements and then
+// fills in the database, user and password of cfg, so a ; in them does not
+// split a statement. The database and user are escaped for a backquoted
+// identifier and the password for a quoted string literal.
+func bootstrapStatements(template string, cfg config.ClickHouse) []string {
+	statements := splitStatements(template)
+	for i, statement := range statements {
+		statements[i] = os.Expand(statement, func(name string) string {
+			switch name {
+			case "CLICKHOUSE_DB":
+				return escapeIdentifier(cfg.DB)
+			case "CLICKHOUSE_USERNAME":
+				return escapeIdentifier(cfg.Username)
+			case "CLICKHOUSE_PASSWORD":
+				return escapeString(cfg.Password)
+			}
+			return ""
+		})
+	}
+	return statements
+}
+
+// escapeString escapes s for a single-quoted ClickHouse string literal, where
+// a backslash starts an escape sequence.
+func escapeString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+}
+
+// escapeIdentifier escapes s for a backquoted ClickHouse identifier.
+func escapeIdentifier(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(s)
+}
+
+// splitStatements splits sql on semicolons and drops statements that are
+// empty or consist of comments only.
+func splitStatements(sql string) []string {
+	var statements []string
+	for _, query := range strings.Split(sql, ";") {
+		query = strings.TrimSpace(query)
+		if query == "" || commentOnly(query) {
+			continue
+		}
+		statements = append(statements, query)
+	}
+	return statements
+}
+
+func commentOnly(query string) bool {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// RunMigrations bootstraps the database and applies every pending migration.
+func RunMigrations(cfg config.Config) error {
+	m, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+	if err := m.Bootstrap(ctx); err != nil {
+		return err
+	}
+	return m.Up(ctx)
 }
@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFile matches versioned migration files such as 0001_create_tables.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of numbered up and down SQL files. Checksum is computed
+// from the up file and detects migrations edited after they were applied.
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	HasDown  bool
+	Checksum string
+}
+
+// AppliedMigration is the state of a version recorded in schema_migrations.
+type AppliedMigration struct {
+	Version   uint64
+	Name      string
+	Checksum  string
+	Applied   bool
+	ChangedAt time.Time
+}
+
+// MigrationStatus is one line of `migrate status`.
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the up file changed after the migration was applied.
+	Modified bool
+	// Missing is set when an applied migration has no file anymore.
+	Missing bool
+}
+
+// LoadMigrations reads the versioned migrations in dir sorted by version.
+// Other files, such as initial_migration.sql, are ignored.
+func LoadMigrations(dir string) ([]Migration, error) {
+	return LoadMigrationsFS(os.DirFS(dir))
+}
+
+// LoadMigrationsFS is LoadMigrations for the root of fsys, such as the
+// migrations a storage backend embeds in its binary.
+func LoadMigrationsFS(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file: %v", err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			sum := sha256.Sum256(data)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(data)
+			migration.HasDown = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// LatestVersion returns the highest version in migrations, or 0 if there are none.
+func LatestVersion(migrations []Migration) uint64 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// verify checks that every applied migration still has a file with the same checksum.
+func verify(migrations []Migration, applied map[uint64]AppliedMigration) error {
+	byVersion := make(map[uint64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+	for _, version := range sortedVersions(applied) {
+		state := applied[version]
+		migration, ok := byVersion[version]
+		if !ok {
+			return fmt.Errorf("migration %d_%s is applied but its file is missing", version, state.Name)
+		}
+		if migration.Checksum != state.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied: checksum %s, applied %s",
+				version, migration.Name, migration.Checksum, state.Checksum)
+		}
+	}
+	return nil
+}
+
+// planUp returns the migrations up to and including target that are not
+// applied yet, oldest first.
+func planUp(migrations []Migration, applied map[uint64]AppliedMigration, target uint64) []Migration {
+	var plan []Migration
+	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			plan = append(plan, migration)
+		}
+	}
+	return plan
+}
+
+// planDown returns the applied migrations above target, newest first. At
+// most n migrations are returned unless n is negative.
+func planDown(migrations []Migration, applied map[uint64]AppliedMigration, target uint64, n int) ([]Migration, error) {
+	var plan []Migration
+	for i := len(migrations) - 1; i >= 0 && n != 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if !migration.HasDown {
+			return nil, fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+		plan = append(plan, migration)
+		n--
+	}
+	return plan, nil
+}
+
+func status(migrations []Migration, applied map[uint64]AppliedMigration) []MigrationStatus {
+	var statuses []MigrationStatus
+	known := make(map[uint64]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		line := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if state, ok := applied[migration.Version]; ok {
+			line.Applied = true
+			line.AppliedAt = state.ChangedAt
+			line.Modified = state.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, line)
+	}
+	for _, version := range sortedVersions(applied) {
+		if !known[version] {
+			state := applied[version]
+			statuses = append(statuses, MigrationStatus{
+				Version:   version,
+				Name:      state.Name,
+				Applied:   true,
+				AppliedAt: state.ChangedAt,
+				Missing:   true,
+			})
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses
+}
+
+func sortedVersions(applied map[uint64]AppliedMigration) []uint64 {
+	versions := make([]uint64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	return versions
+}
@@ -0,0 +1,182 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0002_add_index.up.sql":      "ALTER TABLE t ADD INDEX i (a) TYPE minmax",
+		"0001_create_table.up.sql":   "CREATE TABLE t (a Int64) ENGINE = Memory",
+		"0001_create_table.down.sql": "DROP TABLE t",
+		"initial_migration.sql":      "CREATE DATABASE db",
+		"README.md":                  "not a migration",
+	})
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, but got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_table" || !migrations[0].HasDown {
+		t.Errorf("unexpected first migration %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].HasDown {
+		t.Errorf("unexpected second migration %+v", migrations[1])
+	}
+	if migrations[0].Checksum == "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("expected distinct checksums, but got %q and %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+	if LatestVersion(migrations) != 2 {
+		t.Errorf("expected latest version 2, but got %d", LatestVersion(migrations))
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"down without up": {"0001_a.down.sql": "DROP TABLE t"},
+		"different names": {"0001_a.up.sql": "SELECT 1", "0001_b.down.sql": "SELECT 1"},
+		"zero version":    {"0000_a.up.sql": "SELECT 1"},
+	} {
+		if _, err := LoadMigrations(writeMigrations(t, files)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadMigrations_RepositoryFiles(t *testing.T) {
+	migrations, err := LoadMigrations(filepath.Join("..", "..", "migration"))
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != uint64(i+1) {
+			t.Errorf("expected version %d, but got %d", i+1, migration.Version)
+		}
+		if !migration.HasDown {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestPlans(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "a", Checksum: "1", HasDown: true},
+		{Version: 2, Name: "b", Checksum: "2", HasDown: true},
+		{Version: 3, Name: "c", Checksum: "3", HasDown: true},
+	}
+	applied := map[uint64]AppliedMigration{
+		1: {Version: 1, Name: "a", Checksum: "1", Applied: true},
+		2: {Version: 2, Name: "b", Checksum: "2", Applied: true},
+	}
+
+	if plan := planUp(migrations, applied, 3); len(plan) != 1 || plan[0].Version != 3 {
+		t.Errorf("expected up plan [3], but got %v", versions(plan))
+	}
+	if plan := planUp(migrations, applied, 2); len(plan) != 0 {
+		t.Errorf("expected empty up plan to version 2, but got %v", versions(plan))
+	}
+
+	plan, err := planDown(migrations, applied, 0, 1)
+	if err != nil || len(plan) != 1 || plan[0].Version != 2 {
+		t.Errorf("expected down plan [2], but got %v, %v", versions(plan), err)
+	}
+	plan, err = planDown(migrations, applied, 0, -1)
+	if err != nil || len(plan) != 2 || plan[0].Version != 2 || plan[1].Version != 1 {
+		t.Errorf("expected down plan [2 1], but got %v, %v", versions(plan), err)
+	}
+	plan, err = planDown(migrations, applied, 1, -1)
+	if err != nil || len(plan) != 1 || plan[0].Version != 2 {
+		t.Errorf("expected down plan to version 1 [2], but got %v, %v", versions(plan), err)
+	}
+
+	migrations[1].HasDown = false
+	if _, err := planDown(migrations, applied, 0, 1); err == nil {
+		t.Errorf("expected an error rolling back a migration without down file")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "a", Checksum: "new"}}
+
+	if err := verify(migrations, map[uint64]AppliedMigration{1: {Version: 1, Name: "a", Checksum: "new"}}); err != nil {
+		t.Errorf("verify() error = %v", err)
+	}
+
+	err := verify(migrations, map[uint64]AppliedMigration{1: {Version: 1, Name: "a", Checksum: "old"}})
+	if err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("expected a modified migration error, but got %v", err)
+	}
+
+	err = verify(migrations, map[uint64]AppliedMigration{2: {Version: 2, Name: "b", Checksum: "2"}})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected a missing migration error, but got %v", err)
+	}
+
+	statuses := status(migrations, map[uint64]AppliedMigration{
+		1: {Version: 1, Name: "a", Checksum: "old"},
+		2: {Version: 2, Name: "b", Checksum: "2"},
+	})
+	if len(statuses) != 2 || !statuses[0].Modified || !statuses[1].Missing {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`
+		-- create the table
+		CREATE TABLE t (a Int64) ENGINE = Memory;
+
+		DROP TABLE t;
+		-- trailing comment
+	`)
+	if len(statements) != 2 || !strings.HasSuffix(statements[0], "ENGINE = Memory") || statements[1] != "DROP TABLE t" {
+		t.Errorf("unexpected statements %q", statements)
+	}
+}
+
+func TestBootstrapStatements(t *testing.T) {
+	statements := bootstrapStatements(
+		"CREATE DATABASE IF NOT EXISTS `${CLICKHOUSE_DB}`;\n"+
+			"CREATE USER IF NOT EXISTS `${CLICKHOUSE_USERNAME}` IDENTIFIED BY '${CLICKHOUSE_PASSWORD}';",
+		config.ClickHouse{DB: "stats`; DROP DATABASE x; --", Username: "service", Password: `it's;a\`},
+	)
+	want := []string{
+		"CREATE DATABASE IF NOT EXISTS `stats\\`; DROP DATABASE x; --`",
+		"CREATE USER IF NOT EXISTS `service` IDENTIFIED BY 'it\\'s;a\\\\'",
+	}
+	if len(statements) != len(want) {
+		t.Fatalf("expected %q, but got %q", want, statements)
+	}
+	for i := range want {
+		if statements[i] != want[i] {
+			t.Errorf("expected %q, but got %q", want[i], statements[i])
+		}
+	}
+}
+
+func versions(migrations []Migration) []uint64 {
+	var result []uint64
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
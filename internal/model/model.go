@@ -5,11 +5,12 @@ import (
 )
 
 type OrderBook struct {
-	ID        int64        `json:"id"`
-	Exchange  string       `json:"exchange"`
-	Pair      string       `json:"pair"`
-	Asks      []DepthOrder `json:"asks"`
-	Bids      []DepthOrder `json:"bids"`
+	ID           int64        `json:"id"`
+	Exchange     string       `json:"exchange"`
+	Pair         string       `json:"pair"`
+	SnapshotTime time.Time    `json:"snapshot_time"`
+	Asks         []DepthOrder `json:"asks"`
+	Bids         []DepthOrder `json:"bids"`
 }
 
 type DepthOrder struct {
@@ -17,25 +18,92 @@ type DepthOrder struct {
 	BaseQty float64 `json:"base_qty"`
 }
 
+// HistoryOrder is an order placed by a client. ID and IngestedAt are assigned
+// by the service when the order is saved.
 type HistoryOrder struct {
-	ClientName            string    `json:"client_name"`
-	ExchangeName          string    `json:"exchange_name"`
-	Label                 string    `json:"label"`
-	Pair                  string    `json:"pair"`
-	Side                  string    `json:"side"`
-	TypeOrder             string    `json:"type_order"`
-	BaseQty               float64   `json:"base_qty"`
-	Price                 float64   `json:"price"`
-	AlgorithmNamePlaced   string    `json:"algorithm_name_placed"`
-	LowestSellPrc         float64   `json:"lowest_sell_prc"`
-	HighestBuyPrc         float64   `json:"highest_buy_prc"`
-	CommissionQuoteQty    float64   `json:"commission_quote_qty"`
-	TimePlaced            time.Time `json:"time_placed"`
+	ID                  int64     `json:"id"`
+	ClientName          string    `json:"client_name"`
+	ExchangeName        string    `json:"exchange_name"`
+	Label               string    `json:"label"`
+	Pair                string    `json:"pair"`
+	Side                string    `json:"side"`
+	TypeOrder           string    `json:"type_order"`
+	BaseQty             float64   `json:"base_qty"`
+	Price               float64   `json:"price"`
+	AlgorithmNamePlaced string    `json:"algorithm_name_placed"`
+	LowestSellPrc       float64   `json:"lowest_sell_prc"`
+	HighestBuyPrc       float64   `json:"highest_buy_prc"`
+	CommissionQuoteQty  float64   `json:"commission_quote_qty"`
+	TimePlaced          time.Time `json:"time_placed"`
+	IngestedAt          time.Time `json:"ingested_at"`
 }
 
+// Client identifies who placed an order. RegisteredAt is set by the service
+// for clients in the registry.
 type Client struct {
-	ClientName   string `json:"client_name"`
-	ExchangeName string `json:"exchange_name"`
-	Label        string `json:"label"`
-	Pair         string `json:"pair"`
+	ClientName   string    `json:"client_name"`
+	ExchangeName string    `json:"exchange_name"`
+	Label        string    `json:"label"`
+	Pair         string    `json:"pair"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// HistoryFilter narrows an order history query. Empty string fields and zero
+// times are not applied. From is inclusive and To is exclusive.
+type HistoryFilter struct {
+	ClientName          string
+	ExchangeName        string
+	Label               string
+	Pair                string
+	Side                string
+	TypeOrder           string
+	AlgorithmNamePlaced string
+	From                time.Time
+	To                  time.Time
+	Limit               int
+	Cursor              string
+}
+
+type HistoryPage struct {
+	Orders     []*HistoryOrder `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// CandleQuery selects candles whose start time is within [From, To).
+type CandleQuery struct {
+	ExchangeName string
+	Pair         string
+	Interval     time.Duration
+	From         time.Time
+	To           time.Time
+}
+
+// Candle aggregates the orders placed within one interval. Volume is in the
+// base currency.
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+	Trades uint64    `json:"trades"`
+}
+
+// AlgorithmActivity sums the orders one algorithm placed on one exchange and
+// pair. Notionals are base_qty * price, in the quote currency.
+type AlgorithmActivity struct {
+	AlgorithmNamePlaced string
+	ExchangeName        string
+	Pair                string
+	Fills               uint64
+	BuyQty              float64
+	BuyNotional         float64
+	SellQty             float64
+	SellNotional        float64
+	Commission          float64
+	// SlippageBps is the sum of the slippage versus the touch, in basis
+	// points, of the SlippageOrders orders with a usable top of book.
+	SlippageBps    float64
+	SlippageOrders uint64
 }
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// bulkChunkSize is the number of accepted lines written per batch insert.
+const bulkChunkSize = 1000
+
+// maxBulkLineLength is the longest line accepted, in bytes. Longer lines are
+// rejected without being read into memory.
+const maxBulkLineLength = 1 << 20
+
+// maxBulkRejectedLines caps the rejected lines listed in the report, so the
+// report of a large upload stays small; the counts cover every line.
+const maxBulkRejectedLines = 1000
+
+const bulkRejected = "rejected"
+
+type bulkLineResult struct {
+	Line   int                     `json:"line"`
+	Status string                  `json:"status"`
+	Error  string                  `json:"error,omitempty"`
+	Fields []validation.FieldError `json:"fields,omitempty"`
+}
+
+type bulkReport struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	// Lines lists the rejected lines, at most maxBulkRejectedLines of them.
+	Lines []bulkLineResult `json:"lines"`
+	// Truncated is set when more lines were rejected than Lines lists.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// reject counts result as rejected and lists it while there is room.
+func (r *bulkReport) reject(result bulkLineResult) {
+	r.Rejected++
+	if len(r.Lines) >= maxBulkRejectedLines {
+		r.Truncated = true
+		return
+	}
+	result.Status = bulkRejected
+	r.Lines = append(r.Lines, result)
+}
+
+// handleBulkOrderHistory loads newline-delimited HistoryOrder JSON. Every
+// non-empty line is validated on its own and valid lines are written in
+// chunks, so a bad line or a failed chunk does not reject the whole request.
+// Only one chunk of orders is held in memory at a time.
+func (s *server) handleBulkOrderHistory(w http.ResponseWriter, r *http.Request) {
+	report := bulkReport{Lines: []bulkLineResult{}}
+	var (
+		pending []*model.HistoryOrder
+		// pendingLines holds the line number of each pending order.
+		pendingLines []int
+		// registered remembers registry lookups across lines.
+		registered = make(map[model.Client]bool)
+	)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if err := s.statistic.SaveOrders(r.Context(), pending); err != nil {
+			for _, line := range pendingLines {
+				report.reject(bulkLineResult{Line: line, Error: fmt.Sprintf("failed to save order: %v", err)})
+			}
+		} else {
+			report.Accepted += len(pending)
+		}
+		pending, pendingLines = nil, nil
+	}
+
+	reader := bufio.NewReaderSize(r.Body, maxBulkLineLength)
+	for line := 1; ; line++ {
+		data, tooLong, err := readBulkLine(reader)
+		if err != nil && !errors.Is(err, io.EOF) {
+			badRequest(w, fmt.Sprintf("failed to read request body at line %d: %v", line, err))
+			return
+		}
+		if tooLong {
+			report.reject(bulkLineResult{Line: line, Error: fmt.Sprintf("line is longer than %d bytes", maxBulkLineLength)})
+		} else if data = bytes.TrimSpace(data); len(data) > 0 {
+			order, lineErr := decodeBulkOrder(data)
+			if lineErr == nil {
+				lineErr = s.checkRegistered(r.Context(), orderClient(order), registered)
+			}
+			if lineErr != nil {
+				result := bulkLineResult{Line: line, Error: lineErr.Error()}
+				var fieldErrs validation.Errors
+				if errors.As(lineErr, &fieldErrs) {
+					result.Error = "order has invalid fields"
+					result.Fields = fieldErrs
+				}
+				report.reject(result)
+			} else {
+				pending = append(pending, order)
+				pendingLines = append(pendingLines, line)
+				if len(pending) >= bulkChunkSize {
+					flush()
+					if r.Context().Err() != nil {
+						break
+					}
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	flush()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := r.Context().Err(); err != nil {
+		// The report covers the chunks written before the request was
+		// cancelled; the remaining lines were not read.
+		w.WriteHeader(errorStatus(err))
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// readBulkLine reads the next line of reader. A line that does not fit in
+// the buffer of reader is skipped up to its end and reported as tooLong. The
+// returned data is only valid until the next read.
+func readBulkLine(reader *bufio.Reader) (data []byte, tooLong bool, err error) {
+	data, err = reader.ReadSlice('\n')
+	for errors.Is(err, bufio.ErrBufferFull) {
+		tooLong = true
+		_, err = reader.ReadSlice('\n')
+	}
+	if tooLong {
+		data = nil
+	}
+	return data, tooLong, err
+}
+
+func decodeBulkOrder(data []byte) (*model.HistoryOrder, error) {
+	var order model.HistoryOrder
+	if err := decodeStrict(data, &order); err != nil {
+		return nil, fmt.Errorf("failed to decode order: %v", err)
+	}
+	if err := validation.HistoryOrder(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
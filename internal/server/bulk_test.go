@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestBulkOrderHistory(t *testing.T) {
+	sv := newTestServer(t)
+
+	body := `{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", "side": "buy", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"}
+{"client_name": "Alice", "exchange_name": "Binance", "pair": "BTC/USD", "side": "buy"}
+
+not json
+{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", "side": "sell", "base_qty": 0.2, "price": 10001, "time_placed": "2024-06-28T12:01:00Z", "unknown": 1}
+{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", "side": "sell", "base_qty": 0.2, "price": 10001, "time_placed": "2024-06-28T12:01:00Z"}`
+
+	w := doRequest(sv, http.MethodPost, "/bulk/order-history", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var report bulkReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Accepted != 2 || report.Rejected != 3 {
+		t.Errorf("expected 2 accepted and 3 rejected lines, but got %d and %d", report.Accepted, report.Rejected)
+	}
+	expected := []int{2, 4, 5}
+	if len(report.Lines) != len(expected) {
+		t.Fatalf("expected only the rejected lines %v, but got %+v", expected, report.Lines)
+	}
+	for i, result := range report.Lines {
+		if result.Line != expected[i] || result.Status != bulkRejected || result.Error == "" {
+			t.Errorf("expected line %d to be rejected with an error, but got %+v", expected[i], result)
+		}
+	}
+
+	orderHistory, err := sv.statistic.GetOrderHistory(context.Background(), &model.Client{ClientName: "Alice", ExchangeName: "Binance", Label: "Order1", Pair: "BTC/USD"})
+	if err != nil {
+		t.Fatalf("GetOrderHistory() error = %v", err)
+	}
+	if len(orderHistory) != 2 {
+		t.Errorf("expected 2 stored orders, but got %d", len(orderHistory))
+	}
+}
+
+func TestBulkOrderHistory_LongLine(t *testing.T) {
+	sv := newTestServer(t)
+
+	order := `{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", "side": "buy", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"}`
+	body := `{"label": "` + strings.Repeat("x", maxBulkLineLength) + `"}` + "\n" + order
+
+	w := doRequest(sv, http.MethodPost, "/bulk/order-history", body)
+	var report bulkReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Accepted != 1 || report.Rejected != 1 || len(report.Lines) != 1 || report.Lines[0].Line != 1 {
+		t.Errorf("expected the long line 1 to be rejected and line 2 accepted, but got %+v", report)
+	}
+}
+
+func TestBulkOrderHistory_TruncatesRejectedLines(t *testing.T) {
+	sv := newTestServer(t)
+
+	body := strings.Repeat("not json\n", maxBulkRejectedLines+5)
+	w := doRequest(sv, http.MethodPost, "/bulk/order-history", body)
+	var report bulkReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Rejected != maxBulkRejectedLines+5 || len(report.Lines) != maxBulkRejectedLines || !report.Truncated {
+		t.Errorf("expected %d rejected lines with %d listed, but got %d with %d listed (truncated %v)",
+			maxBulkRejectedLines+5, maxBulkRejectedLines, report.Rejected, len(report.Lines), report.Truncated)
+	}
+}
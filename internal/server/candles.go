@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// defaultCandles is the number of candles returned when from is not set.
+const defaultCandles = 100
+
+func (s *server) handleGetCandles(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCandleQuery(r)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if err := validation.Market(query.ExchangeName, query.Pair); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	candles, err := s.statistic.GetCandles(r.Context(), query)
+	if errors.Is(err, statistic.ErrInvalidCandleQuery) {
+		badRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		storageError(w, "failed to get candles", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candles)
+}
+
+func parseCandleQuery(r *http.Request) (*model.CandleQuery, error) {
+	params := r.URL.Query()
+	exchangeName, pair := marketParams(r)
+	query := &model.CandleQuery{
+		ExchangeName: exchangeName,
+		Pair:         pair,
+		Interval:     time.Minute,
+		To:           time.Now().UTC(),
+	}
+
+	var err error
+	if interval := params.Get("interval"); interval != "" {
+		if query.Interval, err = parseCandleInterval(interval); err != nil {
+			return nil, err
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339Nano, to); err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	if from := params.Get("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339Nano, from); err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+	} else {
+		query.From = query.To.Add(-defaultCandles * query.Interval)
+	}
+
+	return query, nil
+}
+
+// parseCandleInterval accepts Go durations such as 5m or 4h, and days such as 1d.
+func parseCandleInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if days == "1" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("invalid interval %q: the only supported day interval is 1d", s)
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: %v", s, err)
+	}
+	return interval, nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+func (s *server) handleListClients(w http.ResponseWriter, r *http.Request) {
+	filter := clientFromQuery(r)
+	if filter.Pair != "" {
+		if err := validation.Pair(filter.Pair); err != nil {
+			validationError(w, err)
+			return
+		}
+	}
+
+	clients, err := s.statistic.ListClients(r.Context(), filter)
+	if err != nil {
+		storageError(w, "failed to list clients", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clients)
+}
+
+func (s *server) handleRegisterClient(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read request body: %v", err))
+		return
+	}
+	var client model.Client
+	if err := decodeStrict(body, &client); err != nil {
+		badRequest(w, fmt.Sprintf("failed to decode request body: %v", err))
+		return
+	}
+	if err := validation.Client(&client); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	if err := s.statistic.RegisterClient(r.Context(), &client); err != nil {
+		if errors.Is(err, statistic.ErrClientExists) {
+			writeError(w, http.StatusConflict, codeConflict, err.Error())
+			return
+		}
+		storageError(w, "failed to register client", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(client)
+}
+
+// handleDeleteClient removes the client identified by the query parameters.
+// An absent label means the client was registered without one.
+func (s *server) handleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	client := clientFromQuery(r)
+	if err := validation.Client(client); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	if err := s.statistic.DeleteClient(r.Context(), client); err != nil {
+		if errors.Is(err, statistic.ErrClientNotFound) {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+			return
+		}
+		storageError(w, "failed to delete client", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientFromQuery reads a client from the query parameters, taking the name
+// from the {client} path segment when the route has one.
+func clientFromQuery(r *http.Request) *model.Client {
+	query := r.URL.Query()
+	clientName := r.PathValue("client")
+	if clientName == "" {
+		clientName = query.Get("client_name")
+	}
+	return &model.Client{
+		ClientName:   clientName,
+		ExchangeName: query.Get("exchange_name"),
+		Label:        query.Get("label"),
+		Pair:         query.Get("pair"),
+	}
+}
+
+func orderClient(order *model.HistoryOrder) *model.Client {
+	return &model.Client{
+		ClientName:   order.ClientName,
+		ExchangeName: order.ExchangeName,
+		Label:        order.Label,
+		Pair:         order.Pair,
+	}
+}
+
+// checkRegistered returns statistic.ErrClientNotFound when registration is
+// required and client is not in the registry. Lookups are remembered in
+// known, if it is not nil, for the rest of a request.
+func (s *server) checkRegistered(ctx context.Context, client *model.Client, known map[model.Client]bool) error {
+	if !s.requireRegisteredClients {
+		return nil
+	}
+
+	registered, ok := known[*client]
+	if !ok {
+		var err error
+		if registered, err = s.statistic.ClientRegistered(ctx, client); err != nil {
+			return fmt.Errorf("failed to look up client: %w", err)
+		}
+		if known != nil {
+			known[*client] = registered
+		}
+	}
+	if !registered {
+		return statistic.ErrClientNotFound
+	}
+	return nil
+}
+
+func registrationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, statistic.ErrClientNotFound) {
+		writeError(w, http.StatusForbidden, codeNotRegistered, err.Error())
+		return
+	}
+	storageError(w, "failed to save order", err)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestClients(t *testing.T) {
+	sv := newTestServer(t)
+
+	for _, body := range []string{
+		`{"client_name": "Alice", "exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD"}`,
+		`{"client_name": "Bob", "exchange_name": "Kraken", "label": "bot2", "pair": "ETH/USD"}`,
+	} {
+		w := doRequest(sv, http.MethodPost, "/clients", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var client model.Client
+		if err := json.NewDecoder(w.Body).Decode(&client); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if client.RegisteredAt.IsZero() {
+			t.Errorf("expected registered_at to be set, but got %+v", client)
+		}
+	}
+
+	w := doRequest(sv, http.MethodPost, "/clients", `{"client_name": "Alice", "exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for a duplicate client, but got %d", http.StatusConflict, w.Code)
+	}
+	w = doRequest(sv, http.MethodPost, "/clients", `{"client_name": "Alice", "pair": "btc"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid client, but got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/clients?exchange_name=Kraken", "")
+	var clients []model.Client
+	if err := json.NewDecoder(w.Body).Decode(&clients); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(clients) != 1 || clients[0].ClientName != "Bob" {
+		t.Errorf("expected only Bob, but got status %d: %+v", w.Code, clients)
+	}
+
+	w = doRequest(sv, http.MethodDelete, "/clients?client_name=Bob&exchange_name=Kraken&label=bot2&pair=ETH/USD", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, but got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	w = doRequest(sv, http.MethodDelete, "/clients?client_name=Bob&exchange_name=Kraken&label=bot2&pair=ETH/USD", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a deleted client, but got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = doRequest(sv, http.MethodPut, "/clients", "")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+		t.Errorf("expected status %d with an Allow header, but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestRequireRegisteredClients(t *testing.T) {
+	sv := newTestServer(t)
+	sv.requireRegisteredClients = true
+
+	order := `"exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD", "side": "buy", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"}`
+	w := doRequest(sv, http.MethodPost, "/clients", `{"client_name": "Alice", "exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w = doRequest(sv, http.MethodPost, "/save-order-history", `{"client_name": "Alice", `+order)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a registered client to be accepted, but got status %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(sv, http.MethodPost, "/save-order-history", `{"client_name": "Mallory", `+order)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for an unregistered client, but got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = doRequest(sv, http.MethodPost, "/bulk/order-history", `{"client_name": "Alice", `+order+"\n"+`{"client_name": "Mallory", `+order)
+	var report bulkReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Accepted != 1 || report.Rejected != 1 || len(report.Lines) != 1 || report.Lines[0].Line != 2 {
+		t.Errorf("expected only the registered client's line to be accepted, but got %+v", report)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// Error codes of the JSON error envelope.
+const (
+	codeBadRequest       = "bad_request"
+	codeValidationFailed = "validation_failed"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeNotRegistered    = "client_not_registered"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeUnavailable      = "unavailable"
+	codeClientClosed     = "client_closed_request"
+	codeTimeout          = "timeout"
+	codeInternal         = "internal"
+)
+
+// errorResponse is the body of every error response:
+//
+//	{"error": {"code": "validation_failed", "message": "...", "fields": [...]}}
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    string                  `json:"code"`
+	Message string                  `json:"message"`
+	Fields  []validation.FieldError `json:"fields,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorResponse(w, status, apiError{Code: code, Message: message})
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, body apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: body})
+}
+
+func badRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, codeBadRequest, message)
+}
+
+// validationError reports the invalid fields of a request. Errors other than
+// validation.Errors are reported as a plain bad request.
+func validationError(w http.ResponseWriter, err error) {
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		badRequest(w, err.Error())
+		return
+	}
+	writeErrorResponse(w, http.StatusBadRequest, apiError{
+		Code:    codeValidationFailed,
+		Message: "request has invalid fields",
+		Fields:  fieldErrs,
+	})
+}
+
+// storageError writes err from a failed storage call with errorStatus.
+func storageError(w http.ResponseWriter, message string, err error) {
+	status := errorStatus(err)
+	code := codeInternal
+	switch status {
+	case StatusClientClosedRequest:
+		code = codeClientClosed
+	case http.StatusGatewayTimeout:
+		code = codeTimeout
+	}
+	writeError(w, status, code, fmt.Sprintf("%s: %v", message, err))
+}
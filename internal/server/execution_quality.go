@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/analytics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// maxListedOutliers caps the outlier orders listed in an execution-quality
+// report. The outlier counts of its groups are not capped.
+const maxListedOutliers = 100
+
+type executionQualityResponse struct {
+	GroupBy    []string `json:"group_by"`
+	OutlierBps float64  `json:"outlier_bps"`
+	*analytics.SlippageReport
+}
+
+// handleGetExecutionQuality reports the slippage of the orders matching the
+// history filter parameters versus the top of book recorded with them.
+func (s *server) handleGetExecutionQuality(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := parseAnalyticsFilter(query)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	dimensions, err := parseDimensions(query.Get("group_by"), analytics.SlippageDimensions, analytics.SlippageDimensions)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	outlierBps := s.slippageOutlierBps
+	if value := query.Get("outlier_bps"); value != "" {
+		outlierBps, err = strconv.ParseFloat(value, 64)
+		if err != nil || outlierBps <= 0 || outlierBps > 1e4 {
+			badRequest(w, fmt.Sprintf("invalid outlier_bps %q: expected a number between 0 and 10000", value))
+			return
+		}
+	}
+	if err := validation.HistoryFilter(filter); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	orders, err := s.loadOrders(r.Context(), filter)
+	if errors.Is(err, errTooManyOrders) {
+		badRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		storageError(w, "failed to get order history", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(executionQualityResponse{
+		GroupBy:        dimensions,
+		OutlierBps:     outlierBps,
+		SlippageReport: analytics.ComputeSlippage(orders, dimensions, outlierBps, maxListedOutliers),
+	})
+}
+
+// parseDimensions parses a comma-separated list of grouping dimensions out
+// of allowed. defaults are used when value is empty.
+func parseDimensions(value string, allowed, defaults []string) ([]string, error) {
+	if value == "" {
+		return defaults, nil
+	}
+	var dimensions []string
+	for _, part := range strings.Split(value, ",") {
+		dimension := strings.TrimSpace(part)
+		if !slices.Contains(allowed, dimension) {
+			return nil, fmt.Errorf("invalid group_by %q: expected comma-separated dimensions out of %s",
+				value, strings.Join(allowed, ", "))
+		}
+		if !slices.Contains(dimensions, dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	return dimensions, nil
+}
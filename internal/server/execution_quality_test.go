@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestGetExecutionQuality(t *testing.T) {
+	sv := newTestServer(t)
+
+	placed := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	order := func(algorithm, side string, price float64) *model.HistoryOrder {
+		return &model.HistoryOrder{
+			ClientName: "Alice", ExchangeName: "Binance", Label: "Order1", Pair: "BTC/USD",
+			Side: side, TypeOrder: "limit", BaseQty: 0.1, Price: price, AlgorithmNamePlaced: algorithm,
+			LowestSellPrc: 10000, HighestBuyPrc: 9990, TimePlaced: placed,
+		}
+	}
+	orders := []*model.HistoryOrder{
+		order("twap", "buy", 10001),
+		order("twap", "sell", 9989),
+		// 100 bps above the ask, beyond the default threshold of 50.
+		order("sniper", "buy", 10100),
+	}
+	if err := sv.statistic.SaveOrders(context.Background(), orders); err != nil {
+		t.Fatalf("SaveOrders() error = %v", err)
+	}
+
+	w := doRequest(sv, http.MethodGet, "/v1/execution-quality?client_name=Alice&group_by=algorithm", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var response executionQualityResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.OutlierBps != 50 || len(response.GroupBy) != 1 || len(response.Groups) != 2 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if sniper := response.Groups[0]; sniper.AlgorithmNamePlaced != "sniper" || sniper.Outliers != 1 || sniper.TouchBps.Max != 100 {
+		t.Errorf("unexpected sniper group %+v", sniper)
+	}
+	if len(response.Outliers) != 1 || response.Outliers[0].Order.AlgorithmNamePlaced != "sniper" {
+		t.Errorf("expected the sniper order to be the only outlier, but got %+v", response.Outliers)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/execution-quality?side=buy&outlier_bps=0.5", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	response = executionQualityResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.GroupBy) != 3 || len(response.Outliers) != 2 {
+		t.Errorf("expected both buys beyond 0.5 bps grouped by every dimension, but got %+v", response)
+	}
+
+	for _, target := range []string{
+		"/v1/execution-quality?group_by=client",
+		"/v1/execution-quality?outlier_bps=-1",
+		"/v1/execution-quality?cursor=abc",
+	} {
+		w := doRequest(sv, http.MethodGet, target, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got %d: %s", target, http.StatusBadRequest, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+)
+
+// readyTimeout bounds the readiness checks, so a hung ClickHouse fails the
+// probe instead of outliving the orchestrator's own probe timeout.
+const readyTimeout = 2 * time.Second
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks []statistic.Check `json:"checks,omitempty"`
+}
+
+// handleHealthz reports that the process is up and serving requests. It
+// does not touch the storage, so a database outage does not get the
+// process restarted.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the service should receive traffic: the
+// storage checks pass and the server is not shutting down.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.shuttingDown.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{
+			Status: "not_ready",
+			Checks: []statistic.Check{{Name: "server", Status: statistic.CheckFailed, Error: "shutting down"}},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	checks := s.statistic.Ready(ctx)
+	if !statistic.ChecksPassed(checks) {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "not_ready", Checks: checks})
+		return
+	}
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ready", Checks: checks})
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
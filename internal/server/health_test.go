@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+)
+
+// unreadyStatistics stands in for a storage whose database is down.
+type unreadyStatistics struct {
+	*statistic.MemoryStatistics
+}
+
+func (u *unreadyStatistics) Ready(ctx context.Context) []statistic.Check {
+	return []statistic.Check{
+		{Name: "clickhouse", Status: statistic.CheckFailed, Error: "dial tcp 127.0.0.1:9006: connection refused"},
+		{Name: "write_buffer", Status: statistic.CheckOK},
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	sv := newTestServer(t)
+	sv.statistic = &unreadyStatistics{statistic.NewMemoryStatistics()}
+	sv.setupRoutes()
+
+	w := doRequest(sv, http.MethodGet, "/healthz", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d even with the storage down, but got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodGet, "/readyz", "")
+	var resp healthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || resp.Status != "ready" {
+		t.Errorf("expected a ready service, but got status %d: %+v", w.Code, resp)
+	}
+
+	sv.statistic = &unreadyStatistics{statistic.NewMemoryStatistics()}
+	sv.setupRoutes()
+	w = doRequest(sv, http.MethodGet, "/readyz", "")
+	resp = healthResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || resp.Status != "not_ready" || len(resp.Checks) != 2 || resp.Checks[0].Error == "" {
+		t.Errorf("expected the failed clickhouse check, but got status %d: %+v", w.Code, resp)
+	}
+}
+
+func TestReadyz_ShuttingDown(t *testing.T) {
+	sv := newTestServer(t)
+	if err := sv.Close(context.Background()); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	w := doRequest(sv, http.MethodGet, "/readyz", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d during shutdown, but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	w = doRequest(sv, http.MethodGet, "/healthz", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d during shutdown, but got %d", http.StatusOK, w.Code)
+	}
+}
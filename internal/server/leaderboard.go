@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/analytics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// maxLeaderboardLimit caps the limit parameter of the leaderboard.
+const maxLeaderboardLimit = 1000
+
+type leaderboardResponse struct {
+	SortBy     string                     `json:"sort_by"`
+	Order      string                     `json:"order"`
+	Method     analytics.CostMethod       `json:"method"`
+	GroupBy    []string                   `json:"group_by"`
+	Algorithms []*analytics.AlgorithmRank `json:"algorithms"`
+}
+
+// handleGetLeaderboard ranks the algorithms of the orders matching the
+// history filter parameters. The storage aggregates the orders; realized PnL
+// is matched fill by fill as in /pnl, so the number of orders is limited the
+// same way.
+func (s *server) handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	// PnL needs both sides of every position.
+	filter, err := parseAnalyticsFilter(query, "side")
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	method, err := enumParam(query, "method", analytics.CostMethods)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	dimensions, err := parseDimensions(query.Get("group_by"), analytics.LeaderboardDimensions, []string{})
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	sortBy, err := enumParam(query, "sort_by", analytics.RankMetrics)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	// Less slippage is better, more of everything else is.
+	defaultOrder := []string{"desc", "asc"}
+	if sortBy == analytics.RankBySlippage {
+		defaultOrder = []string{"asc", "desc"}
+	}
+	order, err := enumParam(query, "order", defaultOrder)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	var limit int
+	if value := query.Get("top"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxLeaderboardLimit {
+			badRequest(w, fmt.Sprintf("invalid top: expected an integer between 1 and %d", maxLeaderboardLimit))
+			return
+		}
+	}
+	if err := validation.HistoryFilter(filter); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	activity, err := s.statistic.AlgorithmActivity(r.Context(), filter)
+	if err != nil {
+		storageError(w, "failed to get algorithm activity", err)
+		return
+	}
+	orders, err := s.loadOrders(r.Context(), filter)
+	if errors.Is(err, errTooManyOrders) {
+		badRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		storageError(w, "failed to get order history", err)
+		return
+	}
+	pnls := analytics.ComputePnL(orders, analytics.CostMethod(method), analytics.GroupByAlgorithm)
+
+	ranks := analytics.RankAlgorithms(activity, pnls, dimensions, sortBy, order == "asc")
+	if limit > 0 && len(ranks) > limit {
+		ranks = ranks[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboardResponse{
+		SortBy:     sortBy,
+		Order:      order,
+		Method:     analytics.CostMethod(method),
+		GroupBy:    dimensions,
+		Algorithms: ranks,
+	})
+}
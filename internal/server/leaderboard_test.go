@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestGetLeaderboard(t *testing.T) {
+	sv := newTestServer(t)
+
+	placed := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	order := func(algorithm, exchange, side string, price float64) *model.HistoryOrder {
+		return &model.HistoryOrder{
+			ClientName: "Alice", ExchangeName: exchange, Label: "Order1", Pair: "BTC/USD",
+			Side: side, TypeOrder: "limit", BaseQty: 1, Price: price, AlgorithmNamePlaced: algorithm,
+			LowestSellPrc: 100, HighestBuyPrc: 99, TimePlaced: placed,
+		}
+	}
+	orders := []*model.HistoryOrder{
+		order("twap", "Binance", "buy", 100),
+		order("twap", "Binance", "sell", 99),
+		order("twap", "Kraken", "buy", 99.9),
+		order("sniper", "Binance", "buy", 101),
+		order("sniper", "Binance", "sell", 99),
+	}
+	if err := sv.statistic.SaveOrders(context.Background(), orders); err != nil {
+		t.Fatalf("SaveOrders() error = %v", err)
+	}
+
+	w := doRequest(sv, http.MethodGet, "/v1/algorithms/leaderboard", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var response leaderboardResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.SortBy != "pnl" || response.Order != "desc" || response.Method != "fifo" || len(response.GroupBy) != 0 || len(response.Algorithms) != 2 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if twap := response.Algorithms[0]; twap.AlgorithmNamePlaced != "twap" || twap.Rank != 1 || twap.Fills != 3 || twap.RealizedPnL != -1 {
+		t.Errorf("expected twap first with 3 fills and PnL -1, but got %+v", twap)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/algorithms/leaderboard?sort_by=slippage&group_by=exchange&top=1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	response = leaderboardResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// twap on Kraken bought below the ask.
+	if response.Order != "asc" || len(response.Algorithms) != 1 ||
+		response.Algorithms[0].ExchangeName != "Kraken" || *response.Algorithms[0].AvgSlippageBps >= 0 {
+		t.Errorf("expected the Kraken twap row only, but got %+v", response.Algorithms)
+	}
+
+	for _, target := range []string{
+		"/v1/algorithms/leaderboard?sort_by=sharpe",
+		"/v1/algorithms/leaderboard?group_by=client",
+		"/v1/algorithms/leaderboard?order=up",
+		"/v1/algorithms/leaderboard?top=0",
+		"/v1/algorithms/leaderboard?limit=10",
+		"/v1/algorithms/leaderboard?side=buy",
+		"/v1/algorithms/leaderboard?method=lifo",
+	} {
+		w := doRequest(sv, http.MethodGet, target, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got %d: %s", target, http.StatusBadRequest, w.Code, w.Body)
+		}
+	}
+}
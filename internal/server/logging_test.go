@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/logging"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(config.Log{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+	})
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodGet, "/healthz", "")
+	generated := w.Header().Get(RequestIDHeader)
+	if len(generated) != 32 {
+		t.Errorf("expected a generated request ID, but got %q", generated)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/exchanges/Binance/pairs/BTC-USD/order-book", nil)
+	r.Header.Set(RequestIDHeader, "proxy-42")
+	w = httptest.NewRecorder()
+	sv.srv.Handler.ServeHTTP(w, r)
+	if id := w.Header().Get(RequestIDHeader); id != "proxy-42" {
+		t.Errorf("expected the client's request ID to be echoed, but got %q", id)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	r.Header.Set(RequestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+	w = httptest.NewRecorder()
+	sv.srv.Handler.ServeHTTP(w, r)
+	if id := w.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Errorf("expected an oversized request ID to be replaced, but got %q", id)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected an access log line per request, but got %d: %s", len(lines), logs)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("failed to decode log line: %v", err)
+	}
+	if record["request_id"] != "proxy-42" || record["method"] != "GET" || record["status"] != float64(http.StatusOK) ||
+		record["path"] != "/v1/exchanges/Binance/pairs/BTC-USD/order-book" || record["bytes"].(float64) == 0 {
+		t.Errorf("unexpected access log record: %v", record)
+	}
+}
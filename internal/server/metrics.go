@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+)
+
+// instrument records the requests to handler under route, the path pattern
+// it is registered with, so the metrics have one series per route rather
+// than per URL.
+func (s *server) instrument(route string, handler http.HandlerFunc) http.HandlerFunc {
+	if s.metrics == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		done := s.metrics.RequestStarted(route, r.Method)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			done(rec.status)
+		}()
+		handler(rec, r)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	sv := &server{
+		srv:       &http.Server{},
+		statistic: statistic.Instrument(statistic.NewMemoryStatistics(), m),
+		metrics:   m,
+	}
+	sv.setupRoutes()
+
+	doRequest(sv, http.MethodGet, "/v1/exchanges/Binance/pairs/BTC-USD/order-book", "")
+	doRequest(sv, http.MethodGet, "/v1/exchanges/Kraken/pairs/ETH-USD/order-book", "")
+	doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=btc", "")
+
+	w := doRequest(sv, http.MethodGet, "/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`statistics_http_requests_total{method="GET",route="/v1/exchanges/{exchange}/pairs/{pair}/order-book",status="200"} 2`,
+		`statistics_http_requests_total{method="GET",route="/get-order-book",status="400"} 1`,
+		`statistics_http_request_duration_seconds_bucket{method="GET",route="/get-order-book",status="400",le="0.2"} 1`,
+		`statistics_http_requests_in_flight{route="/get-order-book"} 0`,
+		`statistics_storage_call_duration_seconds_count{method="GetOrderBook"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %s", want)
+		}
+	}
+}
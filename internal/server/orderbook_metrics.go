@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/analytics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// handleGetOrderBookMetrics returns metrics of a single snapshot, selected by
+// the snapshot parameter (latest by default), or a time series of metrics
+// when from or to is set.
+func (s *server) handleGetOrderBookMetrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	exchangeName, pair := marketParams(r)
+	if err := validation.Market(exchangeName, pair); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	depthBps, err := parseDepthBps(query.Get("depth_bps"))
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	if query.Has("from") || query.Has("to") {
+		var from, to time.Time
+		if value := query.Get("from"); value != "" {
+			if from, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				badRequest(w, fmt.Sprintf("invalid from: %v", err))
+				return
+			}
+		}
+		if value := query.Get("to"); value != "" {
+			if to, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				badRequest(w, fmt.Sprintf("invalid to: %v", err))
+				return
+			}
+		}
+		var limit int
+		if value := query.Get("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit <= 0 || limit > statistic.MaxSnapshotLimit {
+				badRequest(w, fmt.Sprintf("invalid limit: expected an integer between 1 and %d", statistic.MaxSnapshotLimit))
+				return
+			}
+		}
+
+		orderBooks, err := s.statistic.ListOrderBookSnapshots(r.Context(), exchangeName, pair, from, to, limit)
+		if err != nil {
+			storageError(w, "failed to list order book snapshots", err)
+			return
+		}
+
+		series := make([]*analytics.OrderBookMetrics, 0, len(orderBooks))
+		for _, orderBook := range orderBooks {
+			series = append(series, analytics.ComputeOrderBookMetrics(orderBook, depthBps))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(series)
+		return
+	}
+
+	snapshot := query.Get("snapshot")
+	if snapshot == "" {
+		snapshot = "latest"
+	}
+	at, err := parseSnapshot(snapshot)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	orderBook, err := s.statistic.GetOrderBookSnapshot(r.Context(), exchangeName, pair, at)
+	if errors.Is(err, statistic.ErrOrderBookNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		storageError(w, "failed to get order book", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics.ComputeOrderBookMetrics(orderBook, depthBps))
+}
+
+// parseDepthBps parses a comma-separated list of positive distances from mid
+// in basis points.
+func parseDepthBps(value string) ([]float64, error) {
+	if value == "" {
+		return analytics.DefaultDepthBps, nil
+	}
+	var depthBps []float64
+	for _, part := range strings.Split(value, ",") {
+		bps, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || bps <= 0 || bps > 1e4 {
+			return nil, fmt.Errorf("invalid depth_bps %q: expected comma-separated numbers between 0 and 10000", value)
+		}
+		depthBps = append(depthBps, bps)
+	}
+	return depthBps, nil
+}
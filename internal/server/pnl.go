@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/analytics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
+)
+
+// maxAnalyzedOrders caps the orders an analytics request reads into memory.
+const maxAnalyzedOrders = 100000
+
+var errTooManyOrders = fmt.Errorf("the request matches more than %d orders, narrow it with from and to", maxAnalyzedOrders)
+
+type pnlResponse struct {
+	Method  analytics.CostMethod  `json:"method"`
+	GroupBy analytics.PnLGrouping `json:"group_by"`
+	Groups  []*analytics.PnL      `json:"groups"`
+}
+
+// handleGetPnL computes realized PnL of the orders matching the history
+// filter parameters, treating every order as a fill.
+func (s *server) handleGetPnL(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	// PnL needs both sides of every position.
+	filter, err := parseAnalyticsFilter(query, "side")
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	method, err := enumParam(query, "method", analytics.CostMethods)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	groupBy, err := enumParam(query, "group_by", analytics.PnLGroupings)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if err := validation.HistoryFilter(filter); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	orders, err := s.loadOrders(r.Context(), filter)
+	if errors.Is(err, errTooManyOrders) {
+		badRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		storageError(w, "failed to get order history", err)
+		return
+	}
+
+	response := pnlResponse{
+		Method:  analytics.CostMethod(method),
+		GroupBy: analytics.PnLGrouping(groupBy),
+		Groups:  analytics.ComputePnL(orders, analytics.CostMethod(method), analytics.PnLGrouping(groupBy)),
+	}
+	if response.Groups == nil {
+		response.Groups = []*analytics.PnL{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseAnalyticsFilter parses the history filter parameters of an analytics
+// request. Paging parameters are rejected, since the whole match is
+// analyzed, and so are the unsupported ones.
+func parseAnalyticsFilter(query url.Values, unsupported ...string) (*model.HistoryFilter, error) {
+	for _, param := range append([]string{"limit", "cursor"}, unsupported...) {
+		if query.Has(param) {
+			return nil, fmt.Errorf("parameter %s is not supported here", param)
+		}
+	}
+	filter, _, err := parseHistoryFilter(query)
+	return filter, err
+}
+
+// enumParam returns the parameter name of query, which must be one of
+// allowed. The first allowed value is the default.
+func enumParam(query url.Values, name string, allowed []string) (string, error) {
+	value := query.Get(name)
+	if value == "" {
+		return allowed[0], nil
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: expected one of %s", name, value, strings.Join(allowed, ", "))
+}
+
+// loadOrders reads every page of orders matching filter, up to
+// maxAnalyzedOrders.
+func (s *server) loadOrders(ctx context.Context, filter *model.HistoryFilter) ([]*model.HistoryOrder, error) {
+	filter.Limit = statistic.MaxHistoryLimit
+	var orders []*model.HistoryOrder
+	for {
+		page, err := s.statistic.QueryOrderHistory(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+		if len(orders) > maxAnalyzedOrders {
+			return nil, errTooManyOrders
+		}
+		if page.NextCursor == "" {
+			return orders, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
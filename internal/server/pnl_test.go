@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestGetPnL(t *testing.T) {
+	sv := newTestServer(t)
+
+	// More orders than fit in one history page, so every page must be read.
+	start := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	var orders []*model.HistoryOrder
+	for i := 0; i < 600; i++ {
+		for j, side := range []string{"buy", "sell"} {
+			orders = append(orders, &model.HistoryOrder{
+				ClientName: "Alice", ExchangeName: "Binance", Label: "Order1", Pair: "BTC/USD",
+				Side: side, TypeOrder: "limit", BaseQty: 1, Price: 100 + float64(j),
+				AlgorithmNamePlaced: "twap", CommissionQuoteQty: 0.1,
+				TimePlaced: start.Add(time.Duration(2*i+j) * time.Second),
+			})
+		}
+	}
+	if err := sv.statistic.SaveOrders(context.Background(), orders); err != nil {
+		t.Fatalf("SaveOrders() error = %v", err)
+	}
+
+	w := doRequest(sv, http.MethodGet, "/v1/pnl?client_name=Alice&method=average", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var response pnlResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Method != "average" || response.GroupBy != "client" || len(response.Groups) != 1 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	pnl := response.Groups[0]
+	if pnl.Fills != 1200 || pnl.NetPosition != 0 || pnl.AvgEntryPrice != nil {
+		t.Errorf("expected 1200 fills and a flat position, but got %+v", pnl)
+	}
+	if math.Abs(pnl.RealizedPnL-600) > 1e-6 || math.Abs(pnl.NetPnL-480) > 1e-6 {
+		t.Errorf("expected realized PnL 600 and net PnL 480, but got %v and %v", pnl.RealizedPnL, pnl.NetPnL)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/pnl?group_by=algorithm&to=2024-06-28T12:00:01Z", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	response = pnlResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Groups) != 1 || response.Groups[0].AlgorithmNamePlaced != "twap" || response.Groups[0].NetPosition != 1 {
+		t.Errorf("expected the open twap position of the first fill, but got %+v", response.Groups)
+	}
+}
+
+func TestGetPnL_InvalidParameters(t *testing.T) {
+	sv := newTestServer(t)
+
+	for _, target := range []string{
+		"/v1/pnl?method=lifo",
+		"/v1/pnl?group_by=pair",
+		"/v1/pnl?limit=10",
+		"/v1/pnl?side=buy",
+		"/v1/pnl?from=yesterday",
+	} {
+		w := doRequest(sv, http.MethodGet, target, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got %d: %s", target, http.StatusBadRequest, w.Code, w.Body)
+		}
+	}
+}
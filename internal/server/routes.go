@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func (s *server) setupRoutes() {
+	rt := router{server: s, mx: http.NewServeMux(), methods: make(map[string][]string)}
+
+	// Every handler is registered under its /v1 resource path and the
+	// legacy path it had before versioning.
+	rt.handle(s.handleGetOrderBook, "GET /v1/exchanges/{exchange}/pairs/{pair}/order-book", "GET /get-order-book")
+	rt.handle(s.handleSaveOrderBook, "POST /v1/exchanges/{exchange}/pairs/{pair}/order-book", "POST /save-order-book")
+	rt.handle(s.handleGetOrderBookMetrics, "GET /v1/exchanges/{exchange}/pairs/{pair}/order-book/metrics", "GET /order-book/metrics")
+	rt.handle(s.handleGetCandles, "GET /v1/exchanges/{exchange}/pairs/{pair}/candles", "GET /candles")
+	rt.handle(s.handleGetClientOrders, "GET /v1/clients/{client}/orders")
+	rt.handle(s.handleGetPnL, "GET /v1/pnl", "GET /pnl")
+	rt.handle(s.handleGetExecutionQuality, "GET /v1/execution-quality", "GET /execution-quality")
+	rt.handle(s.handleGetLeaderboard, "GET /v1/algorithms/leaderboard", "GET /algorithms/leaderboard")
+	// Legacy clients send the client as a body, which not every HTTP client
+	// allows on GET.
+	rt.handle(s.handleGetOrderHistory, "GET /get-order-history", "POST /get-order-history")
+	rt.handle(s.handleSaveOrderHistory, "POST /v1/orders", "POST /save-order-history")
+	rt.handle(s.handleBulkOrderHistory, "POST /v1/orders/bulk", "POST /bulk/order-history")
+	rt.handle(s.handleListClients, "GET /v1/clients", "GET /clients")
+	rt.handle(s.handleRegisterClient, "POST /v1/clients", "POST /clients")
+	rt.handle(s.handleDeleteClient, "DELETE /v1/clients/{client}", "DELETE /clients")
+	rt.handle(s.handleHealthz, "GET /healthz")
+	rt.handle(s.handleReadyz, "GET /readyz")
+	rt.handle(s.metrics.Handler().ServeHTTP, "GET /metrics")
+
+	rt.rejectOtherMethods()
+	s.srv.Handler = logRequests(rt.mx)
+}
+
+// router registers method and path patterns on a ServeMux and remembers the
+// methods of every path to answer the others with 405.
+type router struct {
+	server  *server
+	mx      *http.ServeMux
+	methods map[string][]string
+}
+
+// handle registers handler under patterns of the form "METHOD /path". The
+// route timeout is the one of the first path found in the configuration.
+func (rt *router) handle(handler http.HandlerFunc, patterns ...string) {
+	paths := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		_, path, _ := strings.Cut(pattern, " ")
+		paths = append(paths, path)
+	}
+	handler = withTimeout(rt.server.routeTimeout(paths...), handler)
+
+	for i, pattern := range patterns {
+		method, _, _ := strings.Cut(pattern, " ")
+		rt.mx.HandleFunc(pattern, rt.server.instrument(paths[i], handler))
+		rt.methods[paths[i]] = append(rt.methods[paths[i]], method)
+	}
+}
+
+// rejectOtherMethods registers a catch-all for every path. Patterns with a
+// method take precedence, so it only sees methods the path does not support.
+func (rt *router) rejectOtherMethods() {
+	for path, methods := range rt.methods {
+		allow := allowedMethods(methods)
+		rt.mx.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", allow)
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+				fmt.Sprintf("method %s is not allowed, use %s", r.Method, allow))
+		})
+	}
+}
+
+// allowedMethods formats the Allow header. GET patterns also serve HEAD.
+func allowedMethods(methods []string) string {
+	allowed := append([]string(nil), methods...)
+	for _, method := range methods {
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+// marketParams returns the exchange and pair of a request, taken from the
+// path on /v1 routes and from the exchange_name and pair query parameters on
+// legacy ones. In the path the pair is either escaped, as in BTC%2FUSD, or
+// written with a dash, as in BTC-USD.
+func marketParams(r *http.Request) (exchangeName, pair string) {
+	exchangeName, pair = r.PathValue("exchange"), r.PathValue("pair")
+	if exchangeName == "" && pair == "" {
+		query := r.URL.Query()
+		return query.Get("exchange_name"), query.Get("pair")
+	}
+	if !strings.Contains(pair, "/") {
+		pair = strings.Replace(pair, "-", "/", 1)
+	}
+	return exchangeName, pair
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func TestResourceRoutes(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodPost, "/v1/exchanges/Binance/pairs/BTC-USD/order-book", `{"asks": [{"price": 10000.5, "base_qty": 0.1}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{
+		"/v1/exchanges/Binance/pairs/BTC-USD/order-book",
+		"/v1/exchanges/Binance/pairs/BTC%2FUSD/order-book",
+		"/get-order-book?exchange_name=Binance&pair=BTC/USD",
+	} {
+		w = doRequest(sv, http.MethodGet, target, "")
+		var orderBook model.OrderBook
+		if err := json.NewDecoder(w.Body).Decode(&orderBook); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", target, err)
+		}
+		if w.Code != http.StatusOK || orderBook.Pair != "BTC/USD" || len(orderBook.Asks) != 1 {
+			t.Errorf("%s: expected the saved order book, but got status %d: %+v", target, w.Code, orderBook)
+		}
+	}
+
+	w = doRequest(sv, http.MethodPost, "/v1/orders", `{"client_name": "Alice", "exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD", "side": "buy", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = doRequest(sv, http.MethodGet, "/v1/clients/Alice/orders?pair=BTC/USD", "")
+	var page model.HistoryPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(page.Orders) != 1 || page.Orders[0].ClientName != "Alice" {
+		t.Errorf("expected the order of Alice, but got status %d: %+v", w.Code, page)
+	}
+	w = doRequest(sv, http.MethodGet, "/v1/clients/Bob/orders", "")
+	page = model.HistoryPage{}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(page.Orders) != 0 {
+		t.Errorf("expected no orders for Bob, but got status %d: %+v", w.Code, page)
+	}
+
+	w = doRequest(sv, http.MethodPost, "/v1/clients", `{"client_name": "Alice", "exchange_name": "Binance", "label": "bot1", "pair": "BTC/USD"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	w = doRequest(sv, http.MethodDelete, "/v1/clients/Alice?exchange_name=Binance&label=bot1&pair=BTC/USD", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, but got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	sv := newTestServer(t)
+
+	tests := []struct {
+		method string
+		target string
+		allow  string
+	}{
+		{http.MethodDelete, "/v1/exchanges/Binance/pairs/BTC-USD/order-book", "GET, HEAD, POST"},
+		{http.MethodPost, "/v1/exchanges/Binance/pairs/BTC-USD/candles", "GET, HEAD"},
+		{http.MethodGet, "/v1/orders", "POST"},
+		{http.MethodGet, "/save-order-history", "POST"},
+		{http.MethodPut, "/clients", "DELETE, GET, HEAD, POST"},
+		{http.MethodPost, "/metrics", "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		w := doRequest(sv, tt.method, tt.target, "")
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status %d, but got %d", tt.method, tt.target, http.StatusMethodNotAllowed, w.Code)
+			continue
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, but got %q", tt.method, tt.target, tt.allow, allow)
+		}
+		var resp errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error.Code != codeMethodNotAllowed {
+			t.Errorf("%s %s: expected a %q error, but got %+v (%v)", tt.method, tt.target, codeMethodNotAllowed, resp, err)
+		}
+	}
+}
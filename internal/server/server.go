@@ -1,153 +1,546 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/validation"
 )
 
-var ErrChannelClosed = errors.New("channel is closed")
+// Shutdown stages reported by ShutdownError.
+const (
+	StageDrain = "drain requests"
+	StageFlush = "flush writes"
+	StageClose = "close storage"
+)
+
+// ShutdownError tells which stage of Close failed.
+type ShutdownError struct {
+	Stage string
+	Err   error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the stage ran out of time.
+func (e *ShutdownError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// ShutdownErrors returns the stage errors joined in an error returned by Close.
+func ShutdownErrors(err error) []*ShutdownError {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+
+	var stageErrs []*ShutdownError
+	for _, err := range errs {
+		var stageErr *ShutdownError
+		if errors.As(err, &stageErr) {
+			stageErrs = append(stageErrs, stageErr)
+		}
+	}
+	return stageErrs
+}
 
 type Server interface {
+	// Run serves requests until the listener fails or ctx is done. It does
+	// not stop the server; call Close for that.
 	Run(ctx context.Context) error
-	Close() error
+	// Close drains in-flight requests, flushes pending writes and closes the
+	// storage, all within ctx. Every stage runs even if an earlier one fails;
+	// the failures are joined ShutdownErrors.
+	Close(ctx context.Context) error
 }
 
 type server struct {
 	srv       *http.Server
 	statistic statistic.IStatistics
+	// requestTimeout and routeTimeouts bound the context of each request;
+	// see routeTimeout.
+	requestTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
+	// requireRegisteredClients rejects orders of clients missing from the registry.
+	requireRegisteredClients bool
+	// bufferedOrderWrites is set when SaveOrder only queues the order for the
+	// batch writer, so saved orders are answered with 202 Accepted.
+	bufferedOrderWrites bool
+	// slippageOutlierBps is the default outlier threshold of the
+	// execution-quality report.
+	slippageOutlierBps float64
+	// metrics is served on /metrics; it may be nil.
+	metrics *metrics.Metrics
+	// shuttingDown fails /readyz once Close has started.
+	shuttingDown atomic.Bool
 }
 
 func (s *server) Run(ctx context.Context) error {
+	// Buffered so the goroutine can exit after Run returned on ctx.Done.
 	ch := make(chan error, 1)
-	defer close(ch)
 	go func() {
 		ch <- s.srv.ListenAndServe()
 	}()
 	select {
-	case err, ok := <-ch:
-		if !ok {
-			return ErrChannelClosed
-		}
-		if err != nil {
+	case err := <-ch:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			return fmt.Errorf("failed to listen and serve: %w", err)
 		}
 	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("context failed: %w", err)
-		}
 	}
 	return nil
 }
 
-func (s *server) Close() error {
-	return nil
+func (s *server) Close(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+	var errs []error
+	if err := s.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, &ShutdownError{Stage: StageDrain, Err: err})
+	}
+	if err := s.statistic.Flush(ctx); err != nil {
+		errs = append(errs, &ShutdownError{Stage: StageFlush, Err: err})
+	}
+
+	// Close flushes again, which only has work to do if a request outlived
+	// the drain stage, so it must not block past the deadline either.
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.statistic.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			errs = append(errs, &ShutdownError{Stage: StageClose, Err: err})
+		}
+	case <-ctx.Done():
+		errs = append(errs, &ShutdownError{Stage: StageClose, Err: ctx.Err()})
+	}
+	return errors.Join(errs...)
 }
 
 func NewServerConfig(cfg config.Config) (Server, error) {
 	srv := http.Server{
 		Addr: net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
 	}
-	statisticservic, err := statistic.NewStatisticsService(cfg.ClickHouse)
+	m := metrics.New()
+	statisticservic, err := statistic.NewStatistics(cfg, m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create statistic: %w", err)
 	}
 
 	sv := server{
-		srv:       &srv,
-		statistic: statisticservic,
+		srv:            &srv,
+		statistic:      statisticservic,
+		requestTimeout: cfg.Server.RequestTimeout,
+		routeTimeouts:  cfg.Server.RouteTimeouts,
+
+		requireRegisteredClients: cfg.Clients.RequireRegistration,
+		bufferedOrderWrites:      statistic.BuffersOrderWrites(cfg),
+		slippageOutlierBps:       cfg.Analytics.SlippageOutlierBps,
+		metrics:                  m,
 	}
 	sv.setupRoutes()
 	return &sv, nil
 }
 
-func (s *server) setupRoutes() {
-	mx := http.NewServeMux()
-
-	mx.HandleFunc("/get-order-book", s.handleGetOrderBook)
-	mx.HandleFunc("/save-order-book", s.handleSaveOrderBook)
-	mx.HandleFunc("/get-order-history", s.handleGetOrderHistory)
-	mx.HandleFunc("/save-order-history", s.handleSaveOrderHistory)
-
-	s.srv.Handler = mx
-}
-
 func (s *server) handleGetOrderBook(w http.ResponseWriter, r *http.Request) {
-	exchangeName := r.URL.Query().Get("exchange_name")
-	pair := r.URL.Query().Get("pair")
+	exchangeName, pair := marketParams(r)
+	if err := validation.Market(exchangeName, pair); err != nil {
+		validationError(w, err)
+		return
+	}
 
-	orderBook, err := s.statistic.GetOrderBook(exchangeName, pair)
+	var (
+		orderBook *model.OrderBook
+		err       error
+	)
+	if snapshot := r.URL.Query().Get("snapshot"); snapshot != "" {
+		var at time.Time
+		at, err = parseSnapshot(snapshot)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		orderBook, err = s.statistic.GetOrderBookSnapshot(r.Context(), exchangeName, pair, at)
+		if errors.Is(err, statistic.ErrOrderBookNotFound) {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+			return
+		}
+	} else {
+		orderBook, err = s.statistic.GetOrderBook(r.Context(), exchangeName, pair)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get order book: %v", err), http.StatusInternalServerError)
+		storageError(w, "failed to get order book", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("format") == "flat" {
+		w.Header().Set("Deprecation", "true")
+		json.NewEncoder(w).Encode(flattenOrderBook(orderBook))
+		return
+	}
 	json.NewEncoder(w).Encode(orderBook)
 }
 
 func (s *server) handleSaveOrderBook(w http.ResponseWriter, r *http.Request) {
-    var orderBook []*model.DepthOrder
-    if err := json.NewDecoder(r.Body).Decode(&orderBook); err != nil {
-        http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
-        return
-    }
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read request body: %v", err))
+		return
+	}
+
+	exchangeName, pair := marketParams(r)
+
+	var orderBook model.OrderBook
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		// Deprecated flat format: a single array where the sign of the price encodes the side.
+		var depthOrders []*model.DepthOrder
+		if err := json.Unmarshal(body, &depthOrders); err != nil {
+			badRequest(w, fmt.Sprintf("failed to decode request body: %v", err))
+			return
+		}
+		orderBook = splitOrderBook(exchangeName, pair, depthOrders)
+		w.Header().Set("Deprecation", "true")
+	} else {
+		if err := json.Unmarshal(body, &orderBook); err != nil {
+			badRequest(w, fmt.Sprintf("failed to decode request body: %v", err))
+			return
+		}
+		if orderBook.Exchange == "" {
+			orderBook.Exchange = exchangeName
+		} else if exchangeName != "" && exchangeName != orderBook.Exchange {
+			badRequest(w, "exchange_name does not match exchange in request body")
+			return
+		}
+		if orderBook.Pair == "" {
+			orderBook.Pair = pair
+		} else if pair != "" && pair != orderBook.Pair {
+			badRequest(w, "pair does not match pair in request body")
+			return
+		}
+	}
+
+	if err := validation.OrderBook(&orderBook); err != nil {
+		validationError(w, err)
+		return
+	}
 
-    exchangeName := r.URL.Query().Get("exchange_name")
-    pair := r.URL.Query().Get("pair")
+	if err := s.statistic.SaveOrderBook(r.Context(), &orderBook); err != nil {
+		storageError(w, "failed to save order book", err)
+		return
+	}
 
-    // Example: Assuming statistic.SaveOrderBook takes []*model.DepthOrder
-    if err := s.statistic.SaveOrderBook(exchangeName, pair, orderBook); err != nil {
-        http.Error(w, fmt.Sprintf("failed to save order book: %v", err), http.StatusInternalServerError)
-        return
-    }
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orderBook)
+}
 
-    w.WriteHeader(http.StatusOK)
+// parseSnapshot parses the snapshot query parameter: "latest", returned as the
+// zero time, or an RFC 3339 timestamp.
+func parseSnapshot(snapshot string) (time.Time, error) {
+	if snapshot == "latest" {
+		return time.Time{}, nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, snapshot)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid snapshot: expected \"latest\" or an RFC 3339 timestamp: %v", err)
+	}
+	return at, nil
 }
 
+// splitOrderBook converts the deprecated flat format into an order book:
+// levels with a positive price are asks, the rest are bids stored with the
+// absolute price.
+func splitOrderBook(exchangeName, pair string, depthOrders []*model.DepthOrder) model.OrderBook {
+	orderBook := model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+	}
+	for _, order := range depthOrders {
+		if order.Price > 0 {
+			orderBook.Asks = append(orderBook.Asks, *order)
+		} else {
+			orderBook.Bids = append(orderBook.Bids, model.DepthOrder{Price: math.Abs(order.Price), BaseQty: order.BaseQty})
+		}
+	}
+	return orderBook
+}
 
+// flattenOrderBook is the inverse of splitOrderBook: asks first, then bids
+// with a negated price.
+func flattenOrderBook(orderBook *model.OrderBook) []*model.DepthOrder {
+	depthOrders := make([]*model.DepthOrder, 0, len(orderBook.Asks)+len(orderBook.Bids))
+	for _, ask := range orderBook.Asks {
+		depthOrders = append(depthOrders, &model.DepthOrder{Price: ask.Price, BaseQty: ask.BaseQty})
+	}
+	for _, bid := range orderBook.Bids {
+		depthOrders = append(depthOrders, &model.DepthOrder{Price: -bid.Price, BaseQty: bid.BaseQty})
+	}
+	return depthOrders
+}
 
 func (s *server) handleGetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	filter, paged, err := parseHistoryFilter(r.URL.Query())
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
 	var client model.Client
-	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-		http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&client); err != nil && !(paged && errors.Is(err, io.EOF)) {
+		badRequest(w, fmt.Sprintf("failed to decode request body: %v", err))
+		return
+	}
+
+	if !paged {
+		if err := validation.Client(&client); err != nil {
+			validationError(w, err)
+			return
+		}
+		orderHistory, err := s.statistic.GetOrderHistory(r.Context(), &client)
+		if err != nil {
+			storageError(w, "failed to get order history", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orderHistory)
+		return
+	}
+
+	for _, field := range []struct {
+		value *string
+		body  string
+	}{
+		{&filter.ClientName, client.ClientName},
+		{&filter.ExchangeName, client.ExchangeName},
+		{&filter.Label, client.Label},
+		{&filter.Pair, client.Pair},
+	} {
+		if *field.value == "" {
+			*field.value = field.body
+		}
+	}
+
+	s.writeHistoryPage(w, r, filter)
+}
+
+// handleGetClientOrders serves GET /v1/clients/{client}/orders, the
+// paginated order history of one client narrowed by query parameters.
+func (s *server) handleGetClientOrders(w http.ResponseWriter, r *http.Request) {
+	filter, _, err := parseHistoryFilter(r.URL.Query())
+	if err != nil {
+		badRequest(w, err.Error())
 		return
 	}
+	filter.ClientName = r.PathValue("client")
 
-	orderHistory, err := s.statistic.GetOrderHistory(&client)
+	s.writeHistoryPage(w, r, filter)
+}
+
+func (s *server) writeHistoryPage(w http.ResponseWriter, r *http.Request, filter *model.HistoryFilter) {
+	if err := validation.HistoryFilter(filter); err != nil {
+		validationError(w, err)
+		return
+	}
+
+	page, err := s.statistic.QueryOrderHistory(r.Context(), filter)
+	if errors.Is(err, statistic.ErrInvalidCursor) {
+		badRequest(w, err.Error())
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get order history: %v", err), http.StatusInternalServerError)
+		storageError(w, "failed to get order history", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orderHistory)
+	json.NewEncoder(w).Encode(page)
+}
+
+// historyFilterParams switch /get-order-history from the legacy array
+// response to a paginated HistoryPage.
+var historyFilterParams = []string{
+	"client_name", "exchange_name", "label", "pair",
+	"from", "to", "side", "type_order", "algorithm", "limit", "cursor",
+}
+
+func parseHistoryFilter(query url.Values) (*model.HistoryFilter, bool, error) {
+	paged := false
+	for _, param := range historyFilterParams {
+		if query.Has(param) {
+			paged = true
+			break
+		}
+	}
+
+	filter := &model.HistoryFilter{
+		ClientName:          query.Get("client_name"),
+		ExchangeName:        query.Get("exchange_name"),
+		Label:               query.Get("label"),
+		Pair:                query.Get("pair"),
+		Side:                query.Get("side"),
+		TypeOrder:           query.Get("type_order"),
+		AlgorithmNamePlaced: query.Get("algorithm"),
+		Cursor:              query.Get("cursor"),
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339Nano, from); err != nil {
+			return nil, false, fmt.Errorf("invalid from: %v", err)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339Nano, to); err != nil {
+			return nil, false, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > statistic.MaxHistoryLimit {
+			return nil, false, fmt.Errorf("invalid limit: expected an integer between 1 and %d", statistic.MaxHistoryLimit)
+		}
+	}
+
+	return filter, paged, nil
+}
+
+// saveOrderRequest is the envelope form of the /save-order-history body. The
+// client fields left empty in Order are taken from Client.
+type saveOrderRequest struct {
+	Client *model.Client       `json:"client"`
+	Order  *model.HistoryOrder `json:"order"`
 }
 
 func (s *server) handleSaveOrderHistory(w http.ResponseWriter, r *http.Request) {
-	var order model.HistoryOrder
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, fmt.Sprintf("failed to decode request body: %v", err), http.StatusBadRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read request body: %v", err))
 		return
 	}
 
-	var client model.Client
-	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-		http.Error(w, fmt.Sprintf("failed to decode client from request body: %v", err), http.StatusBadRequest)
+	order, err := decodeSaveOrderRequest(body)
+	if err != nil {
+		validationError(w, err)
+		return
+	}
+	if err := validation.HistoryOrder(order); err != nil {
+		validationError(w, err)
 		return
 	}
 
-	if err := s.statistic.SaveOrder(&client, &order); err != nil {
-		http.Error(w, fmt.Sprintf("failed to save order: %v", err), http.StatusInternalServerError)
+	client := orderClient(order)
+	if err := s.checkRegistered(r.Context(), client, nil); err != nil {
+		registrationError(w, err)
+		return
+	}
+	if err := s.statistic.SaveOrder(r.Context(), client, order); err != nil {
+		if errors.Is(err, statistic.ErrWriteBufferFull) {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+			return
+		}
+		storageError(w, "failed to save order", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if s.bufferedOrderWrites {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
+// decodeSaveOrderRequest accepts either a HistoryOrder with its client fields
+// or a saveOrderRequest envelope. Client fields set in both parts of the
+// envelope must match.
+func decodeSaveOrderRequest(body []byte) (*model.HistoryOrder, error) {
+	var probe struct {
+		Client json.RawMessage `json:"client"`
+		Order  json.RawMessage `json:"order"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("failed to decode request body: %v", err)
+	}
+
+	if probe.Client == nil && probe.Order == nil {
+		var order model.HistoryOrder
+		if err := decodeStrict(body, &order); err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %v", err)
+		}
+		return &order, nil
+	}
+
+	var request saveOrderRequest
+	if err := decodeStrict(body, &request); err != nil {
+		return nil, fmt.Errorf("failed to decode request body: %v", err)
+	}
+	if request.Order == nil {
+		return nil, errors.New("failed to decode request body: order is required")
+	}
+	if request.Client == nil {
+		return request.Order, nil
+	}
+
+	var mismatches validation.Errors
+	for _, field := range []struct {
+		name   string
+		order  *string
+		client string
+	}{
+		{"client_name", &request.Order.ClientName, request.Client.ClientName},
+		{"exchange_name", &request.Order.ExchangeName, request.Client.ExchangeName},
+		{"label", &request.Order.Label, request.Client.Label},
+		{"pair", &request.Order.Pair, request.Client.Pair},
+	} {
+		switch {
+		case *field.order == "":
+			*field.order = field.client
+		case field.client != "" && field.client != *field.order:
+			mismatches = append(mismatches, validation.FieldError{
+				Field:   "order." + field.name,
+				Message: fmt.Sprintf("does not match client.%s %q", field.name, field.client),
+			})
+		}
+	}
+	if len(mismatches) > 0 {
+		return nil, mismatches
+	}
+	return request.Order, nil
+}
+
+// decodeStrict decodes a single JSON value from data into v and rejects
+// unknown fields and trailing data.
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after JSON object")
+	}
+	return nil
 }
@@ -0,0 +1,554 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/statistic"
+)
+
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	sv := &server{
+		srv:       &http.Server{},
+		statistic: statistic.NewMemoryStatistics(),
+
+		slippageOutlierBps: config.Default().Analytics.SlippageOutlierBps,
+	}
+	sv.setupRoutes()
+	return sv
+}
+
+func doRequest(s *server, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestSaveOrderBook(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodPost, "/save-order-book", `{
+		"exchange": "Binance",
+		"pair": "BTC/USD",
+		"asks": [{"price": 10000.5, "base_qty": 0.1}],
+		"bids": [{"price": 9999.5, "base_qty": 0.3}]
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var orderBook model.OrderBook
+	if err := json.NewDecoder(w.Body).Decode(&orderBook); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(orderBook.Asks) != 1 || orderBook.Asks[0].Price != 10000.5 {
+		t.Errorf("unexpected asks %v", orderBook.Asks)
+	}
+	if len(orderBook.Bids) != 1 || orderBook.Bids[0].Price != 9999.5 {
+		t.Errorf("unexpected bids %v", orderBook.Bids)
+	}
+}
+
+func TestSaveOrderBookFlatFormat(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodPost, "/save-order-book?exchange_name=Binance&pair=BTC/USD",
+		`[{"price": 10000.5, "base_qty": 0.1}, {"price": -9999.5, "base_qty": 0.3}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if w.Header().Get("Deprecation") == "" {
+		t.Errorf("expected Deprecation header for flat format")
+	}
+
+	orderBook, err := sv.statistic.GetOrderBook(context.Background(), "Binance", "BTC/USD")
+	if err != nil {
+		t.Fatalf("GetOrderBook() error = %v", err)
+	}
+	if len(orderBook.Bids) != 1 || orderBook.Bids[0].Price != 9999.5 {
+		t.Errorf("expected bid stored with absolute price, but got %v", orderBook.Bids)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD&format=flat", "")
+	var depthOrders []model.DepthOrder
+	if err := json.NewDecoder(w.Body).Decode(&depthOrders); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := []model.DepthOrder{{Price: 10000.5, BaseQty: 0.1}, {Price: -9999.5, BaseQty: 0.3}}
+	if len(depthOrders) != len(expected) || depthOrders[0] != expected[0] || depthOrders[1] != expected[1] {
+		t.Errorf("expected flat order book %v, but got %v", expected, depthOrders)
+	}
+}
+
+func TestSaveOrderBookMismatchedPair(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodPost, "/save-order-book?pair=ETH/USD", `{"exchange": "Binance", "pair": "BTC/USD"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetOrderBookSnapshot(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD&snapshot=latest", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, but got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = doRequest(sv, http.MethodPost, "/save-order-book", `{"exchange": "Binance", "pair": "BTC/USD", "asks": [{"price": 10000.5, "base_qty": 0.1}]}`)
+	var saved model.OrderBook
+	if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if saved.ID == 0 || saved.SnapshotTime.IsZero() {
+		t.Fatalf("expected server-assigned id and snapshot time, but got %+v", saved)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD&snapshot="+saved.SnapshotTime.Format(time.RFC3339Nano), "")
+	var orderBook model.OrderBook
+	if err := json.NewDecoder(w.Body).Decode(&orderBook); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if orderBook.ID != saved.ID {
+		t.Errorf("expected snapshot %d, but got %d", saved.ID, orderBook.ID)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD&snapshot=yesterday", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// failingStatistics stands in for a storage whose queries fail.
+type failingStatistics struct {
+	*statistic.MemoryStatistics
+}
+
+func (f *failingStatistics) GetOrderBook(ctx context.Context, exchangeName, pair string) (*model.OrderBook, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func (f *failingStatistics) GetOrderBookSnapshot(ctx context.Context, exchangeName, pair string, at time.Time) (*model.OrderBook, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestGetOrderBookStorageError(t *testing.T) {
+	sv := newTestServer(t)
+	sv.statistic = &failingStatistics{statistic.NewMemoryStatistics()}
+	sv.setupRoutes()
+
+	for _, target := range []string{
+		"/get-order-book?exchange_name=Binance&pair=BTC/USD",
+		"/get-order-book?exchange_name=Binance&pair=BTC/USD&format=flat",
+		"/get-order-book?exchange_name=Binance&pair=BTC/USD&snapshot=latest",
+		"/get-order-book?exchange_name=Binance&pair=BTC/USD&snapshot=latest&format=flat",
+	} {
+		w := doRequest(sv, http.MethodGet, target, "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d, but got %d: %s", target, http.StatusInternalServerError, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGetOrderHistoryPaged(t *testing.T) {
+	sv := newTestServer(t)
+
+	client := &model.Client{ClientName: "Alice", ExchangeName: "Binance", Label: "Client1", Pair: "BTC/USD"}
+	start := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		order := &model.HistoryOrder{Side: "buy", BaseQty: float64(i + 1), TimePlaced: start.Add(time.Duration(i) * time.Minute)}
+		if err := sv.statistic.SaveOrder(context.Background(), client, order); err != nil {
+			t.Fatalf("SaveOrder() error = %v", err)
+		}
+	}
+
+	w := doRequest(sv, http.MethodGet, "/get-order-history?client_name=Alice&from=2024-06-28T12:01:00Z&limit=1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var page model.HistoryPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].BaseQty != 2 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/get-order-history?client_name=Alice&from=2024-06-28T12:01:00Z&limit=1&cursor="+page.NextCursor, "")
+	page = model.HistoryPage{}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].BaseQty != 3 || page.NextCursor != "" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "from=yesterday", "cursor=abc"} {
+		w = doRequest(sv, http.MethodGet, "/get-order-history?"+query, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+	sv := newTestServer(t)
+
+	client := &model.Client{ClientName: "Alice", ExchangeName: "Binance", Label: "Client1", Pair: "BTC/USD"}
+	start := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	for i, price := range []float64{100, 110, 90} {
+		order := &model.HistoryOrder{Side: "buy", Price: price, BaseQty: 1, TimePlaced: start.Add(time.Duration(i) * time.Hour)}
+		if err := sv.statistic.SaveOrder(context.Background(), client, order); err != nil {
+			t.Fatalf("SaveOrder() error = %v", err)
+		}
+	}
+
+	w := doRequest(sv, http.MethodGet, "/candles?exchange_name=Binance&pair=BTC/USD&interval=1d&from=2024-06-28T00:00:00Z&to=2024-06-29T00:00:00Z", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var candles []model.Candle
+	if err := json.NewDecoder(w.Body).Decode(&candles); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := model.Candle{Time: start.Truncate(24 * time.Hour), Open: 100, High: 110, Low: 90, Close: 90, Volume: 3, Trades: 3}
+	if len(candles) != 1 || candles[0] != expected {
+		t.Errorf("expected candles [%+v], but got %+v", expected, candles)
+	}
+
+	for _, query := range []string{"interval=2d", "interval=30s", "interval=abc", "from=2024-06-29T00:00:00Z&to=2024-06-28T00:00:00Z"} {
+		w = doRequest(sv, http.MethodGet, "/candles?exchange_name=Binance&pair=BTC/USD&"+query, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestGetOrderBookMetrics(t *testing.T) {
+	sv := newTestServer(t)
+
+	w := doRequest(sv, http.MethodGet, "/order-book/metrics?exchange_name=Binance&pair=BTC/USD", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, but got %d", http.StatusNotFound, w.Code)
+	}
+
+	for _, ask := range []float64{101, 102} {
+		orderBook := &model.OrderBook{
+			Exchange: "Binance",
+			Pair:     "BTC/USD",
+			Asks:     []model.DepthOrder{{Price: ask, BaseQty: 1}},
+			Bids:     []model.DepthOrder{{Price: 99, BaseQty: 1}},
+		}
+		if err := sv.statistic.SaveOrderBook(context.Background(), orderBook); err != nil {
+			t.Fatalf("SaveOrderBook() error = %v", err)
+		}
+	}
+
+	w = doRequest(sv, http.MethodGet, "/order-book/metrics?exchange_name=Binance&pair=BTC/USD&depth_bps=100", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var metrics struct {
+		Mid   float64 `json:"mid"`
+		Depth []struct {
+			Bps float64 `json:"bps"`
+		} `json:"depth"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if metrics.Mid != 100.5 || len(metrics.Depth) != 1 || metrics.Depth[0].Bps != 100 {
+		t.Errorf("unexpected metrics of the latest snapshot %+v", metrics)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/order-book/metrics?exchange_name=Binance&pair=BTC/USD&from=2000-01-01T00:00:00Z", "")
+	var series []struct {
+		Mid float64 `json:"mid"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&series); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(series) != 2 || series[0].Mid != 100 || series[1].Mid != 100.5 {
+		t.Errorf("unexpected metrics series %+v", series)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/order-book/metrics?exchange_name=Binance&pair=BTC/USD&depth_bps=-1", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// blockingStatistics stands in for a storage whose flush never completes.
+type blockingStatistics struct {
+	*statistic.MemoryStatistics
+	closed bool
+}
+
+func (b *blockingStatistics) Flush(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *blockingStatistics) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestClose_DrainsInFlightRequests(t *testing.T) {
+	sv := newTestServer(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sv.srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go sv.srv.Serve(l)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- sv.Close(context.Background())
+	}()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close() returned before the in-flight request finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("expected the in-flight request to complete with 200, but got %d", code)
+	}
+}
+
+func TestClose_ReportsTimedOutStage(t *testing.T) {
+	storage := &blockingStatistics{MemoryStatistics: statistic.NewMemoryStatistics()}
+	sv := &server{srv: &http.Server{}, statistic: storage}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := sv.Close(ctx)
+
+	stageErrs := ShutdownErrors(err)
+	if len(stageErrs) == 0 || stageErrs[0].Stage != StageFlush || !stageErrs[0].Timeout() {
+		t.Fatalf("expected the flush stage to time out, but got %v", err)
+	}
+	for _, stageErr := range stageErrs {
+		if stageErr.Stage == StageDrain {
+			t.Errorf("expected draining an idle server to succeed, but got %v", stageErr)
+		}
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	sv := &server{
+		srv:            &http.Server{},
+		statistic:      statistic.NewMemoryStatistics(),
+		requestTimeout: time.Nanosecond,
+		routeTimeouts:  map[string]time.Duration{"/candles": 0},
+	}
+	sv.setupRoutes()
+
+	w := doRequest(sv, http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD", "")
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d when the request deadline passed, but got %d", http.StatusGatewayTimeout, w.Code)
+	}
+
+	w = doRequest(sv, http.MethodGet, "/candles?exchange_name=Binance&pair=BTC/USD", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a route timeout of 0 to disable the deadline, but got status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestClientClosedRequest(t *testing.T) {
+	sv := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/get-order-book?exchange_name=Binance&pair=BTC/USD", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	sv.srv.Handler.ServeHTTP(w, r)
+
+	if w.Code != StatusClientClosedRequest {
+		t.Errorf("expected status %d for a cancelled request, but got %d", StatusClientClosedRequest, w.Code)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	sv := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   string
+		fields []string
+	}{
+		{
+			name:   "save order book",
+			method: http.MethodPost,
+			target: "/save-order-book",
+			body:   `{"exchange": "Binance", "pair": "btc-usd", "asks": [{"price": 10000.5, "base_qty": -1}]}`,
+			code:   codeValidationFailed,
+			fields: []string{"pair", "asks[0].base_qty"},
+		},
+		{
+			name:   "get order book",
+			method: http.MethodGet,
+			target: "/get-order-book?pair=BTC/USD",
+			code:   codeValidationFailed,
+			fields: []string{"exchange_name"},
+		},
+		{
+			name:   "get order history",
+			method: http.MethodGet,
+			target: "/get-order-history?client_name=Alice&side=long",
+			code:   codeValidationFailed,
+			fields: []string{"side"},
+		},
+		{
+			name:   "malformed body",
+			method: http.MethodPost,
+			target: "/save-order-book",
+			body:   `{"exchange": `,
+			code:   codeBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(sv, tt.method, tt.target, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("expected a JSON error, but got Content-Type %q", contentType)
+			}
+
+			var response errorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if response.Error.Code != tt.code || response.Error.Message == "" {
+				t.Errorf("expected code %q with a message, but got %+v", tt.code, response.Error)
+			}
+			var fields []string
+			for _, fieldErr := range response.Error.Fields {
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("expected invalid fields %v, but got %v", tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestSaveOrderHistoryBuffered(t *testing.T) {
+	sv := newTestServer(t)
+	sv.bufferedOrderWrites = true
+
+	w := doRequest(sv, http.MethodPost, "/v1/orders", `{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", "side": "buy", "type_order": "limit", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"}`)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status %d for a buffered write, but got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+}
+
+func TestSaveOrderHistory(t *testing.T) {
+	sv := newTestServer(t)
+
+	order := `"side": "buy", "type_order": "limit", "base_qty": 0.1, "price": 10000.5, "time_placed": "2024-06-28T12:00:00Z"`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"order", `{"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD", ` + order + `}`},
+		{"envelope", `{"client": {"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD"}, "order": {` + order + `}}`},
+		{"envelope with matching client fields", `{"client": {"client_name": "Alice", "exchange_name": "Binance", "label": "Order1", "pair": "BTC/USD"}, "order": {"client_name": "Alice", "pair": "BTC/USD", ` + order + `}}`},
+	}
+	var ids []int64
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(sv, http.MethodPost, "/save-order-history", tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			var saved model.HistoryOrder
+			if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if saved.ID == 0 || saved.IngestedAt.IsZero() {
+				t.Errorf("expected a server-assigned id and ingest time, but got %d and %v", saved.ID, saved.IngestedAt)
+			}
+			if saved.ClientName != "Alice" || saved.Label != "Order1" || saved.Pair != "BTC/USD" || saved.Price != 10000.5 {
+				t.Errorf("expected the stored order to be echoed, but got %+v", saved)
+			}
+			ids = append(ids, saved.ID)
+		})
+	}
+
+	orderHistory, err := sv.statistic.GetOrderHistory(context.Background(), &model.Client{ClientName: "Alice", ExchangeName: "Binance", Label: "Order1", Pair: "BTC/USD"})
+	if err != nil {
+		t.Fatalf("GetOrderHistory() error = %v", err)
+	}
+	if len(orderHistory) != len(tests) {
+		t.Fatalf("expected %d saved orders, but got %d", len(tests), len(orderHistory))
+	}
+	for i, saved := range orderHistory {
+		if i < len(ids) && saved.ID != ids[i] {
+			t.Errorf("expected order %d to have id %d, but got %d", i, ids[i], saved.ID)
+		}
+	}
+
+	w := doRequest(sv, http.MethodPost, "/save-order-history",
+		`{"client": {"client_name": "Alice", "exchange_name": "Binance", "pair": "BTC/USD"}, "order": {"pair": "ETH/USD", `+order+`}}`)
+	var response errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || len(response.Error.Fields) != 1 || response.Error.Fields[0].Field != "order.pair" {
+		t.Errorf("expected a mismatch on order.pair, but got status %d: %+v", w.Code, response.Error)
+	}
+
+	w = doRequest(sv, http.MethodPost, "/save-order-history", `{"client": {"client_name": "Alice"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an envelope without order, but got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// StatusClientClosedRequest is the non-standard status, borrowed from nginx,
+// reported when the client went away before the response was written.
+const StatusClientClosedRequest = 499
+
+// withTimeout applies timeout to the request context of handler, so storage
+// calls stop once it passes. Zero means no deadline.
+func withTimeout(timeout time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	if timeout <= 0 {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// routeTimeout returns the deadline configured for the first of paths found
+// in routeTimeouts, falling back to the server-wide request timeout.
+func (s *server) routeTimeout(paths ...string) time.Duration {
+	for _, path := range paths {
+		if timeout, ok := s.routeTimeouts[path]; ok {
+			return timeout
+		}
+	}
+	return s.requestTimeout
+}
+
+// errorStatus maps a storage error to a response status: 499 when the client
+// cancelled the request, 504 when the route deadline passed, 500 otherwise.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,126 @@
+package statistic
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/analytics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// historyConditions translates the fields of filter that are set into
+// conditions on the history columns, which are named the same in every
+// database.
+func historyConditions(filter *model.HistoryFilter) ([]string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	for _, column := range []struct {
+		name  string
+		value string
+	}{
+		{"client_name", filter.ClientName},
+		{"exchange_name", filter.ExchangeName},
+		{"label", filter.Label},
+		{"pair", filter.Pair},
+		{"side", filter.Side},
+		{"type_order", filter.TypeOrder},
+		{"algorithm_name_placed", filter.AlgorithmNamePlaced},
+	} {
+		if column.value != "" {
+			conditions = append(conditions, column.name+" = ?")
+			args = append(args, column.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "time_placed >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "time_placed < ?")
+		args = append(args, filter.To)
+	}
+	return conditions, args
+}
+
+// usableTopOfBook and touchSlippageBps match analytics.ComputeOrderSlippage.
+const (
+	usableTopOfBook  = "lowest_sell_prc > 0 AND highest_buy_prc > 0 AND lowest_sell_prc >= highest_buy_prc"
+	touchSlippageBps = `CASE WHEN side = 'sell'
+		THEN (highest_buy_prc - price) / highest_buy_prc
+		ELSE (price - lowest_sell_prc) / lowest_sell_prc END * 10000`
+)
+
+// algorithmActivityQuery aggregates the orders of table matching filter per
+// algorithm, exchange and pair. The query is plain SQL that ClickHouse,
+// PostgreSQL and SQLite all run; its columns follow model.AlgorithmActivity.
+func algorithmActivityQuery(table string, filter *model.HistoryFilter) (string, []any) {
+	query := `
+		SELECT algorithm_name_placed, exchange_name, pair, COUNT(*),
+			SUM(CASE WHEN side = 'buy' THEN base_qty ELSE 0 END),
+			SUM(CASE WHEN side = 'buy' THEN base_qty * price ELSE 0 END),
+			SUM(CASE WHEN side = 'sell' THEN base_qty ELSE 0 END),
+			SUM(CASE WHEN side = 'sell' THEN base_qty * price ELSE 0 END),
+			SUM(commission_quote_qty),
+			SUM(CASE WHEN ` + usableTopOfBook + ` THEN ` + touchSlippageBps + ` ELSE 0 END),
+			SUM(CASE WHEN ` + usableTopOfBook + ` THEN 1 ELSE 0 END)
+		FROM ` + table
+	conditions, args := historyConditions(filter)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += `
+		GROUP BY algorithm_name_placed, exchange_name, pair
+		ORDER BY algorithm_name_placed, exchange_name, pair`
+	return query, args
+}
+
+// aggregateAlgorithmActivity is algorithmActivityQuery for orders in memory.
+func aggregateAlgorithmActivity(orders []*model.HistoryOrder) []*model.AlgorithmActivity {
+	type market struct {
+		algorithm, exchange, pair string
+	}
+	byMarket := make(map[market]*model.AlgorithmActivity)
+	activity := []*model.AlgorithmActivity{}
+	for _, order := range orders {
+		key := market{order.AlgorithmNamePlaced, order.ExchangeName, order.Pair}
+		a, ok := byMarket[key]
+		if !ok {
+			a = &model.AlgorithmActivity{
+				AlgorithmNamePlaced: order.AlgorithmNamePlaced,
+				ExchangeName:        order.ExchangeName,
+				Pair:                order.Pair,
+			}
+			byMarket[key] = a
+			activity = append(activity, a)
+		}
+
+		a.Fills++
+		switch order.Side {
+		case "buy":
+			a.BuyQty += order.BaseQty
+			a.BuyNotional += order.BaseQty * order.Price
+		case "sell":
+			a.SellQty += order.BaseQty
+			a.SellNotional += order.BaseQty * order.Price
+		}
+		a.Commission += order.CommissionQuoteQty
+		if slippage, ok := analytics.ComputeOrderSlippage(order); ok {
+			a.SlippageBps += slippage.TouchBps
+			a.SlippageOrders++
+		}
+	}
+
+	sort.Slice(activity, func(i, j int) bool {
+		a, b := activity[i], activity[j]
+		if a.AlgorithmNamePlaced != b.AlgorithmNamePlaced {
+			return a.AlgorithmNamePlaced < b.AlgorithmNamePlaced
+		}
+		if a.ExchangeName != b.ExchangeName {
+			return a.ExchangeName < b.ExchangeName
+		}
+		return a.Pair < b.Pair
+	})
+	return activity
+}
@@ -0,0 +1,286 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+const (
+	defaultBatchSize      = 1000
+	defaultFlushInterval  = time.Second
+	defaultBufferSize     = 10000
+	defaultEnqueueTimeout = 100 * time.Millisecond
+	defaultFlushTimeout   = 10 * time.Second
+	defaultMaxRetries     = 3
+)
+
+var (
+	ErrWriteBufferFull = errors.New("write buffer is full")
+	ErrWriterClosed    = errors.New("writer is closed")
+)
+
+// FlushReport describes a single flush of the order batcher, or the orders
+// it dropped when it stopped.
+type FlushReport struct {
+	Rows     int
+	Duration time.Duration
+	Err      error
+	// Dropped counts the orders given up on: those of a batch that failed
+	// its last retry, or those still unwritten when the batcher stopped.
+	Dropped int
+}
+
+// orderBatcher accumulates history orders and writes them with flush once
+// size orders are buffered or every interval. Enqueue blocks for at most
+// enqueueTimeout when the buffer is full, which pushes back on callers.
+// Every write is cancelled after flushTimeout; a failed batch is written
+// again on each of the next maxRetries intervals before it is dropped.
+type orderBatcher struct {
+	size           int
+	interval       time.Duration
+	enqueueTimeout time.Duration
+	flushTimeout   time.Duration
+	maxRetries     int
+	flush          func(ctx context.Context, orders []model.HistoryOrder) error
+	onFlush        func(FlushReport)
+
+	orders   chan model.HistoryOrder
+	flushReq chan flushRequest
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newOrderBatcher(cfg config.Batch, flush func(ctx context.Context, orders []model.HistoryOrder) error, onFlush func(FlushReport)) *orderBatcher {
+	b := &orderBatcher{
+		size:           cfg.Size,
+		interval:       cfg.FlushInterval,
+		enqueueTimeout: cfg.EnqueueTimeout,
+		flushTimeout:   cfg.FlushTimeout,
+		maxRetries:     cfg.MaxRetries,
+		flush:          flush,
+		onFlush:        onFlush,
+		flushReq:       make(chan flushRequest),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	if b.size <= 0 {
+		b.size = defaultBatchSize
+	}
+	if b.interval <= 0 {
+		b.interval = defaultFlushInterval
+	}
+	if b.enqueueTimeout <= 0 {
+		b.enqueueTimeout = defaultEnqueueTimeout
+	}
+	if b.flushTimeout <= 0 {
+		b.flushTimeout = defaultFlushTimeout
+	}
+	if b.maxRetries <= 0 {
+		b.maxRetries = defaultMaxRetries
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	b.orders = make(chan model.HistoryOrder, bufferSize)
+
+	go b.run()
+	return b
+}
+
+// bufferUsage returns the share of the buffer taken by orders waiting to be
+// batched.
+func (b *orderBatcher) bufferUsage() float64 {
+	return float64(len(b.orders)) / float64(cap(b.orders))
+}
+
+func (b *orderBatcher) enqueue(ctx context.Context, order model.HistoryOrder) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrWriterClosed
+	}
+
+	select {
+	case b.orders <- order:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(b.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case b.orders <- order:
+		return nil
+	case <-timer.C:
+		return ErrWriteBufferFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushRequest asks the run loop to write everything buffered using ctx.
+type flushRequest struct {
+	ctx   context.Context
+	reply chan error
+}
+
+// Flush writes every order enqueued so far, retrying the failed batches, and
+// returns the first flush error. It gives up when ctx is done; the writes in
+// progress are cancelled with it.
+func (b *orderBatcher) Flush(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, reply: make(chan error, 1)}
+	select {
+	case b.flushReq <- req:
+	case <-b.done:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting orders, flushes the ones already enqueued and stops
+// the run loop. Orders still unwritten after the flush are dropped.
+func (b *orderBatcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	err := b.Flush(ctx)
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// failedBatch is a batch waiting to be written again.
+type failedBatch struct {
+	orders   []model.HistoryOrder
+	attempts int
+}
+
+func (b *orderBatcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]model.HistoryOrder, 0, b.size)
+	// failed holds the batches to retry, oldest first.
+	var failed []failedBatch
+	attempt := func(ctx context.Context, pending failedBatch) error {
+		pending.attempts++
+		err := b.write(ctx, pending.orders, pending.attempts > b.maxRetries)
+		if err != nil && pending.attempts <= b.maxRetries {
+			failed = append(failed, pending)
+		}
+		return err
+	}
+	write := func(ctx context.Context) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := attempt(ctx, failedBatch{orders: batch})
+		batch = make([]model.HistoryOrder, 0, b.size)
+		return err
+	}
+	retry := func(ctx context.Context) error {
+		pending := failed
+		failed = nil
+		var firstErr error
+		for _, batch := range pending {
+			if err := attempt(ctx, batch); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	for {
+		select {
+		case order := <-b.orders:
+			batch = append(batch, order)
+			if len(batch) >= b.size {
+				// The failure is reported to onFlush and the batch
+				// retried on the next ticks.
+				_ = write(context.Background())
+			}
+		case <-ticker.C:
+			_ = retry(context.Background())
+			_ = write(context.Background())
+		case req := <-b.flushReq:
+			firstErr := retry(req.ctx)
+			for drained := false; !drained; {
+				select {
+				case order := <-b.orders:
+					batch = append(batch, order)
+					if len(batch) >= b.size {
+						if err := write(req.ctx); err != nil && firstErr == nil {
+							firstErr = err
+						}
+					}
+				default:
+					drained = true
+				}
+			}
+			if err := write(req.ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			req.reply <- firstErr
+		case <-b.stop:
+			dropped := len(batch) + len(b.orders)
+			for _, pending := range failed {
+				dropped += len(pending.orders)
+			}
+			if dropped > 0 && b.onFlush != nil {
+				b.onFlush(FlushReport{Err: ErrWriterClosed, Dropped: dropped})
+			}
+			return
+		}
+	}
+}
+
+// write writes batch within flushTimeout. When last is set and the write
+// fails, the orders of batch are reported as dropped.
+func (b *orderBatcher) write(ctx context.Context, batch []model.HistoryOrder, last bool) error {
+	ctx, cancel := context.WithTimeout(ctx, b.flushTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := b.flush(ctx, batch)
+	if b.onFlush != nil {
+		report := FlushReport{
+			Rows:     len(batch),
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if err != nil && last {
+			report.Dropped = len(batch)
+		}
+		b.onFlush(report)
+	}
+	return err
+}
@@ -0,0 +1,278 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+type recordingFlusher struct {
+	mu      sync.Mutex
+	batches [][]model.HistoryOrder
+	err     error
+	// failures is the number of flushes that fail with err before the
+	// flusher recovers; 0 fails every flush when err is set.
+	failures int
+	block    chan struct{}
+}
+
+func (f *recordingFlusher) flush(ctx context.Context, orders []model.HistoryOrder) error {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]model.HistoryOrder(nil), orders...))
+	if f.failures > 0 && len(f.batches) > f.failures {
+		return nil
+	}
+	return f.err
+}
+
+func (f *recordingFlusher) sizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, batch := range f.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestOrderBatcher_FlushBySize(t *testing.T) {
+	flusher := &recordingFlusher{}
+	b := newOrderBatcher(config.Batch{Size: 2, FlushInterval: time.Hour}, flusher.flush, nil)
+	defer b.Close(context.Background())
+
+	for i := 0; i < 5; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{BaseQty: float64(i)}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(flusher.sizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sizes := flusher.sizes(); len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 2 {
+		t.Fatalf("expected two full batches, but got %v", sizes)
+	}
+
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if sizes := flusher.sizes(); len(sizes) != 3 || sizes[2] != 1 {
+		t.Errorf("expected the remaining order to be flushed, but got %v", sizes)
+	}
+}
+
+func TestOrderBatcher_FlushByInterval(t *testing.T) {
+	flusher := &recordingFlusher{}
+	b := newOrderBatcher(config.Batch{Size: 100, FlushInterval: 10 * time.Millisecond}, flusher.flush, nil)
+	defer b.Close(context.Background())
+
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(flusher.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sizes := flusher.sizes(); len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("expected one batch flushed by interval, but got %v", sizes)
+	}
+}
+
+func TestOrderBatcher_BackPressure(t *testing.T) {
+	flusher := &recordingFlusher{block: make(chan struct{})}
+	b := newOrderBatcher(config.Batch{Size: 1, FlushInterval: time.Hour, BufferSize: 1, EnqueueTimeout: 10 * time.Millisecond}, flusher.flush, nil)
+
+	// The first order is picked up by the flush blocked in the flusher,
+	// the second one fills the buffer.
+	for i := 0; i < 2; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); !errors.Is(err, ErrWriteBufferFull) {
+		t.Errorf("expected ErrWriteBufferFull, but got %v", err)
+	}
+
+	close(flusher.block)
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if sizes := flusher.sizes(); len(sizes) != 2 {
+		t.Errorf("expected both accepted orders to be flushed, but got %v", sizes)
+	}
+}
+
+func TestOrderBatcher_CloseFlushesAndReportsErrors(t *testing.T) {
+	flushErr := errors.New("clickhouse is down")
+	flusher := &recordingFlusher{err: flushErr}
+	var reports []FlushReport
+	b := newOrderBatcher(config.Batch{Size: 100, FlushInterval: time.Hour}, flusher.flush, func(report FlushReport) {
+		reports = append(reports, report)
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+	}
+	if err := b.Close(context.Background()); !errors.Is(err, flushErr) {
+		t.Errorf("expected Close() to return the flush error, but got %v", err)
+	}
+	if len(reports) != 2 || reports[0].Rows != 3 || !errors.Is(reports[0].Err, flushErr) {
+		t.Fatalf("expected a failed flush of 3 rows, but got %+v", reports)
+	}
+	if reports[1].Dropped != 3 {
+		t.Errorf("expected the 3 orders waiting for a retry to be reported as dropped, but got %+v", reports[1])
+	}
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed after Close(), but got %v", err)
+	}
+}
+
+func TestOrderBatcher_CloseTimesOut(t *testing.T) {
+	flusher := &recordingFlusher{block: make(chan struct{})}
+	b := newOrderBatcher(config.Batch{Size: 100, FlushInterval: time.Hour}, flusher.flush, nil)
+
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Close() to time out, but got %v", err)
+	}
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed after Close(), but got %v", err)
+	}
+}
+
+// reportRecorder collects the reports of a batcher.
+type reportRecorder struct {
+	mu      sync.Mutex
+	reports []FlushReport
+}
+
+func (r *reportRecorder) record(report FlushReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+func (r *reportRecorder) get() []FlushReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]FlushReport(nil), r.reports...)
+}
+
+func TestOrderBatcher_FlushTimeout(t *testing.T) {
+	flusher := &recordingFlusher{block: make(chan struct{})}
+	recorder := &reportRecorder{}
+	b := newOrderBatcher(config.Batch{Size: 1, FlushInterval: time.Hour, FlushTimeout: 10 * time.Millisecond}, flusher.flush, recorder.record)
+	defer b.Close(context.Background())
+
+	if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if reports := recorder.get(); len(reports) != 1 || !errors.Is(reports[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("expected the hung flush to time out, but got %+v", reports)
+	}
+	close(flusher.block)
+}
+
+func TestOrderBatcher_RetriesFailedBatches(t *testing.T) {
+	flusher := &recordingFlusher{err: errors.New("clickhouse is down"), failures: 2}
+	recorder := &reportRecorder{}
+	b := newOrderBatcher(config.Batch{Size: 2, FlushInterval: 5 * time.Millisecond, MaxRetries: 2}, flusher.flush, recorder.record)
+
+	for i := 0; i < 2; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(flusher.sizes()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if sizes := flusher.sizes(); len(sizes) != 3 || sizes[2] != 2 {
+		t.Errorf("expected the batch to be written on its second retry, but got %v", sizes)
+	}
+	for _, report := range recorder.get() {
+		if report.Dropped != 0 {
+			t.Errorf("expected no orders to be dropped, but got %+v", report)
+		}
+	}
+}
+
+func TestOrderBatcher_DropsAfterMaxRetries(t *testing.T) {
+	flusher := &recordingFlusher{err: errors.New("clickhouse is down")}
+	recorder := &reportRecorder{}
+	b := newOrderBatcher(config.Batch{Size: 2, FlushInterval: 5 * time.Millisecond, MaxRetries: 1}, flusher.flush, recorder.record)
+	defer b.Close(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.get()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	reports := recorder.get()
+	if len(reports) != 2 || reports[0].Dropped != 0 || reports[1].Dropped != 2 {
+		t.Fatalf("expected the batch to be dropped after one retry, but got %+v", reports)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if sizes := flusher.sizes(); len(sizes) != 2 {
+		t.Errorf("expected no write after the batch was dropped, but got %v", sizes)
+	}
+}
+
+func TestOrderBatcher_ReportsFailedSizeFlush(t *testing.T) {
+	flushErr := errors.New("clickhouse is down")
+	flusher := &recordingFlusher{err: flushErr}
+	recorder := &reportRecorder{}
+	b := newOrderBatcher(config.Batch{Size: 2, FlushInterval: time.Hour}, flusher.flush, recorder.record)
+	defer b.Close(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if err := b.enqueue(context.Background(), model.HistoryOrder{}); err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if reports := recorder.get(); len(reports) != 1 || reports[0].Rows != 2 || !errors.Is(reports[0].Err, flushErr) {
+		t.Errorf("expected the failed flush triggered by size to be reported, but got %+v", reports)
+	}
+}
@@ -0,0 +1,79 @@
+package statistic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+const (
+	MinCandleInterval = time.Minute
+	MaxCandleInterval = 24 * time.Hour
+	MaxCandles        = 10000
+)
+
+var ErrInvalidCandleQuery = errors.New("invalid candle query")
+
+func validateCandleQuery(query *model.CandleQuery) error {
+	switch {
+	case query.Interval < MinCandleInterval || query.Interval > MaxCandleInterval:
+		return fmt.Errorf("%w: interval must be between %v and %v", ErrInvalidCandleQuery, MinCandleInterval, MaxCandleInterval)
+	case query.Interval%time.Minute != 0:
+		return fmt.Errorf("%w: interval must be a whole number of minutes", ErrInvalidCandleQuery)
+	case query.From.IsZero() || query.To.IsZero() || !query.From.Before(query.To):
+		return fmt.Errorf("%w: from must be before to", ErrInvalidCandleQuery)
+	case query.To.Sub(query.From)/query.Interval > MaxCandles:
+		return fmt.Errorf("%w: time range spans more than %d intervals", ErrInvalidCandleQuery, MaxCandles)
+	}
+	return nil
+}
+
+// candleStart returns the start of the interval containing t. Intervals are
+// aligned to the Unix epoch, so daily candles start at midnight UTC.
+func candleStart(t time.Time, interval time.Duration) time.Time {
+	return time.Unix(0, 0).UTC().Add(t.Sub(time.Unix(0, 0)).Truncate(interval))
+}
+
+// candleBounds returns the time range of the orders that make up the candles
+// starting within [query.From, query.To).
+func candleBounds(query *model.CandleQuery) (time.Time, time.Time) {
+	lower := candleStart(query.From, query.Interval)
+	upper := candleStart(query.To.Add(-time.Nanosecond), query.Interval).Add(query.Interval)
+	return lower, upper
+}
+
+// aggregateCandles builds candles for query from orders of its exchange and
+// pair, in any order.
+func aggregateCandles(orders []*model.HistoryOrder, query *model.CandleQuery) []*model.Candle {
+	sorted := append([]*model.HistoryOrder(nil), orders...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TimePlaced.Before(sorted[j].TimePlaced)
+	})
+
+	candles := []*model.Candle{}
+	var current *model.Candle
+	for _, order := range sorted {
+		start := candleStart(order.TimePlaced, query.Interval)
+		if start.Before(query.From) || !start.Before(query.To) {
+			continue
+		}
+		if current == nil || !current.Time.Equal(start) {
+			current = &model.Candle{
+				Time: start,
+				Open: order.Price,
+				High: order.Price,
+				Low:  order.Price,
+			}
+			candles = append(candles, current)
+		}
+		current.High = max(current.High, order.Price)
+		current.Low = min(current.Low, order.Price)
+		current.Close = order.Price
+		current.Volume += order.BaseQty
+		current.Trades++
+	}
+	return candles
+}
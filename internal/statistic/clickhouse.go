@@ -2,50 +2,85 @@ package statistic
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/chclient"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
 )
 
 type StatisticsService struct {
-	conn driver.Conn
-}
-
-func NewStatisticsService(cfg config.ClickHouse) (*StatisticsService, error) {
-	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)},
-		Auth: clickhouse.Auth{
-			Database: cfg.DB,
-			Username: cfg.Username,
-			Password: cfg.Password,
-		},
-		DialTimeout:      5 * time.Minute,
-		ConnOpenStrategy: clickhouse.ConnOpenRoundRobin,
-	})
+	conn         driver.Conn
+	orderBookIDs idSequence
+	historyIDs   idSequence
+	batcher      *orderBatcher
+	// metrics counts inserted rows and batch sizes; it may be nil.
+	metrics *metrics.Metrics
+	// expectedMigration is the schema version Ready requires; 0 skips the check.
+	expectedMigration uint64
+}
+
+func NewStatisticsService(cfg config.ClickHouse, m *metrics.Metrics) (*StatisticsService, error) {
+	conn, err := chclient.Open(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ClickHouse: %v", err)
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
 	}
 
 	ctx := context.Background()
 	if err := conn.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping ClickHouse after connection: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping ClickHouse after connection: %w", err)
+	}
+
+	service := &StatisticsService{
+		conn:         requestConn{conn},
+		metrics:      m,
+		orderBookIDs: idSequence{instance: int64(cfg.InstanceID)},
+		historyIDs:   idSequence{instance: int64(cfg.InstanceID)},
+	}
+
+	var lastOrderBookID int64
+	if err := conn.QueryRow(ctx, "SELECT max(id) FROM OrderBook").Scan(&lastOrderBookID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read last order book id: %w", err)
 	}
+	service.orderBookIDs.seed(lastOrderBookID)
 
-	return &StatisticsService{
-		conn: conn,
-	}, nil
+	var lastHistoryID int64
+	if err := conn.QueryRow(ctx, "SELECT max(id) FROM HistoryOrder").Scan(&lastHistoryID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read last history order id: %w", err)
+	}
+	service.historyIDs.seed(lastHistoryID)
+
+	if cfg.Batch.Enabled {
+		service.batcher = newOrderBatcher(cfg.Batch, service.insertHistoryOrders, service.reportFlush)
+	}
+
+	return service, nil
 }
 
+// Close flushes buffered writes and closes the ClickHouse connection.
 func (s *StatisticsService) Close() error {
-	return s.conn.Close()
+	var flushErr error
+	if s.batcher != nil {
+		flushErr = s.batcher.Close(context.Background())
+	}
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
 }
 
-func (s *StatisticsService) GetOrderBook(exchangeName, pair string) ([]*model.DepthOrder, error) {
-	ctx := context.Background()
+func (s *StatisticsService) GetOrderBook(ctx context.Context, exchangeName, pair string) (*model.OrderBook, error) {
 	query := `
 		SELECT asks, bids
 		FROM OrderBook
@@ -53,122 +88,471 @@ func (s *StatisticsService) GetOrderBook(exchangeName, pair string) ([]*model.De
 	`
 	rows, err := s.conn.Query(ctx, query, exchangeName, pair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query for order book: %v", err)
+		return nil, fmt.Errorf("failed to execute query for order book: %w", err)
 	}
 	defer rows.Close()
 
-	var orderBook []*model.DepthOrder
+	orderBook := &model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+		Asks:     []model.DepthOrder{},
+		Bids:     []model.DepthOrder{},
+	}
 	for rows.Next() {
-		var asks, bids []struct {
-			Price    float64
-			BaseQty  float64
-		}
+		var asks, bids [][]float64
 		if err := rows.Scan(&asks, &bids); err != nil {
-			return nil, fmt.Errorf("failed to scan row for order book: %v", err)
-		}
-		for _, ask := range asks {
-			orderBook = append(orderBook, &model.DepthOrder{Price: ask.Price, BaseQty: ask.BaseQty})
-		}
-		for _, bid := range bids {
-			orderBook = append(orderBook, &model.DepthOrder{Price: bid.Price, BaseQty: bid.BaseQty})
+			return nil, fmt.Errorf("failed to scan row for order book: %w", err)
 		}
+		orderBook.Asks = append(orderBook.Asks, depthOrders(asks)...)
+		orderBook.Bids = append(orderBook.Bids, depthOrders(bids)...)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over order book rows: %v", err)
+		return nil, fmt.Errorf("error iterating over order book rows: %w", err)
 	}
 
 	return orderBook, nil
 }
 
-func (s *StatisticsService) SaveOrderBook(exchangeName, pair string, orderBook []*model.DepthOrder) error {
-	ctx := context.Background()
-	batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO OrderBook (exchange, pair, asks, bids)")
+func (s *StatisticsService) GetOrderBookSnapshot(ctx context.Context, exchangeName, pair string, at time.Time) (*model.OrderBook, error) {
+	query := `
+		SELECT id, snapshot_time, asks, bids
+		FROM OrderBook
+		WHERE exchange = ? AND pair = ?
+	`
+	args := []any{exchangeName, pair}
+	if !at.IsZero() {
+		query += " AND snapshot_time <= ?"
+		args = append(args, at)
+	}
+	query += " ORDER BY snapshot_time DESC, id DESC LIMIT 1"
+
+	orderBook := &model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+	}
+	var asks, bids [][]float64
+	err := s.conn.QueryRow(ctx, query, args...).Scan(&orderBook.ID, &orderBook.SnapshotTime, &asks, &bids)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderBookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order book snapshot: %w", err)
+	}
+	orderBook.Asks = depthOrders(asks)
+	orderBook.Bids = depthOrders(bids)
+
+	return orderBook, nil
+}
+
+func (s *StatisticsService) ListOrderBookSnapshots(ctx context.Context, exchangeName, pair string, from, to time.Time, limit int) ([]*model.OrderBook, error) {
+	query := `
+		SELECT id, snapshot_time, asks, bids
+		FROM OrderBook
+		WHERE exchange = ? AND pair = ?
+	`
+	args := []any{exchangeName, pair}
+	if !from.IsZero() {
+		query += " AND snapshot_time >= ?"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query += " AND snapshot_time < ?"
+		args = append(args, to)
+	}
+	query += fmt.Sprintf(" ORDER BY snapshot_time, id LIMIT %d", snapshotLimit(limit))
+
+	rows, err := s.conn.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to prepare batch: %v", err)
-	}
-
-	var asks, bids []struct {
-		Price   float64
-		BaseQty float64
-	}
-	for _, order := range orderBook {
-		if order.Price > 0 {
-			asks = append(asks, struct {
-				Price   float64
-				BaseQty float64
-			}{order.Price, order.BaseQty})
-		} else {
-			bids = append(bids, struct {
-				Price   float64
-				BaseQty float64
-			}{order.Price, order.BaseQty})
+		return nil, fmt.Errorf("failed to execute query for order book snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	orderBooks := []*model.OrderBook{}
+	for rows.Next() {
+		orderBook := &model.OrderBook{
+			Exchange: exchangeName,
+			Pair:     pair,
+		}
+		var asks, bids [][]float64
+		if err := rows.Scan(&orderBook.ID, &orderBook.SnapshotTime, &asks, &bids); err != nil {
+			return nil, fmt.Errorf("failed to scan row for order book snapshots: %w", err)
 		}
+		orderBook.Asks = depthOrders(asks)
+		orderBook.Bids = depthOrders(bids)
+		orderBooks = append(orderBooks, orderBook)
 	}
 
-	if err := batch.Append(exchangeName, pair, asks, bids); err != nil {
-		return fmt.Errorf("failed to append to batch: %v", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over order book snapshot rows: %w", err)
+	}
+
+	return orderBooks, nil
+}
+
+func (s *StatisticsService) SaveOrderBook(ctx context.Context, orderBook *model.OrderBook) error {
+	batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO OrderBook (id, exchange, pair, snapshot_time, asks, bids)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	snapshotTime := time.Now().UTC().Truncate(time.Millisecond)
+	id := s.orderBookIDs.next(snapshotTime)
+	if err := batch.Append(id, orderBook.Exchange, orderBook.Pair, snapshotTime,
+		depthTuples(orderBook.Asks), depthTuples(orderBook.Bids)); err != nil {
+		return fmt.Errorf("failed to append to batch: %w", err)
 	}
 
 	if err := batch.Send(); err != nil {
-		return fmt.Errorf("failed to send batch: %v", err)
+		return fmt.Errorf("failed to send batch: %w", err)
 	}
+	s.metrics.RowsInserted("OrderBook", 1)
+	orderBook.ID = id
+	orderBook.SnapshotTime = snapshotTime
 
 	return nil
 }
 
-func (s *StatisticsService) GetOrderHistory(client *model.Client) ([]*model.HistoryOrder, error) {
-	ctx := context.Background()
+// depthTuples converts depth levels into the unnamed Tuple(Float64, Float64)
+// representation used by the asks and bids columns.
+func depthTuples(levels []model.DepthOrder) [][]float64 {
+	tuples := make([][]float64, 0, len(levels))
+	for _, level := range levels {
+		tuples = append(tuples, []float64{level.Price, level.BaseQty})
+	}
+	return tuples
+}
+
+func depthOrders(tuples [][]float64) []model.DepthOrder {
+	levels := make([]model.DepthOrder, 0, len(tuples))
+	for _, tuple := range tuples {
+		if len(tuple) != 2 {
+			continue
+		}
+		levels = append(levels, model.DepthOrder{Price: tuple[0], BaseQty: tuple[1]})
+	}
+	return levels
+}
+
+const historyColumns = `id, client_name, exchange_name, label, pair, side, type_order,
+	base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
+	commission_quote_qty, time_placed, ingested_at`
+
+// historyOrderBy matches lessHistoryOrder.
+const historyOrderBy = `time_placed, id`
+
+func (s *StatisticsService) GetOrderHistory(ctx context.Context, client *model.Client) ([]*model.HistoryOrder, error) {
 	query := `
-		SELECT client_name, exchange_name, label, pair, side, type_order,
-			   base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
-			   commission_quote_qty, time_placed
+		SELECT ` + historyColumns + `
 		FROM HistoryOrder
 		WHERE client_name = ? AND exchange_name = ? AND label = ? AND pair = ?
 	`
 	rows, err := s.conn.Query(ctx, query, client.ClientName, client.ExchangeName, client.Label, client.Pair)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query for order history: %v", err)
+		return nil, fmt.Errorf("failed to execute query for order history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHistoryOrders(rows)
+}
+
+func (s *StatisticsService) QueryOrderHistory(ctx context.Context, filter *model.HistoryFilter) (*model.HistoryPage, error) {
+	cursor, err := decodeHistoryCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := historyLimit(filter.Limit)
+
+	conditions, args := historyConditions(filter)
+	if !cursor.Time.IsZero() {
+		// Positional arguments are bound with second precision, so the time
+		// of the cursor is passed in milliseconds.
+		conditions = append(conditions, "(time_placed, id) > (fromUnixTimestamp64Milli(?, 'UTC'), ?)")
+		args = append(args, cursor.Time.UnixMilli(), cursor.ID)
+	}
+
+	query := "SELECT " + historyColumns + " FROM HistoryOrder"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", historyOrderBy, limit+1)
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for order history: %w", err)
 	}
 	defer rows.Close()
 
+	orders, err := scanHistoryOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return historyPage(orders, limit), nil
+}
+
+func scanHistoryOrders(rows driver.Rows) ([]*model.HistoryOrder, error) {
 	var orderHistory []*model.HistoryOrder
 	for rows.Next() {
 		var historyOrder model.HistoryOrder
 		if err := rows.Scan(
-			&historyOrder.ClientName, &historyOrder.ExchangeName, &historyOrder.Label,
+			&historyOrder.ID, &historyOrder.ClientName, &historyOrder.ExchangeName, &historyOrder.Label,
 			&historyOrder.Pair, &historyOrder.Side, &historyOrder.TypeOrder,
 			&historyOrder.BaseQty, &historyOrder.Price, &historyOrder.AlgorithmNamePlaced,
 			&historyOrder.LowestSellPrc, &historyOrder.HighestBuyPrc, &historyOrder.CommissionQuoteQty,
-			&historyOrder.TimePlaced,
+			&historyOrder.TimePlaced, &historyOrder.IngestedAt,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan row for order history: %v", err)
+			return nil, fmt.Errorf("failed to scan row for order history: %w", err)
 		}
 		orderHistory = append(orderHistory, &historyOrder)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over order history rows: %v", err)
+		return nil, fmt.Errorf("error iterating over order history rows: %w", err)
 	}
 
 	return orderHistory, nil
 }
 
-func (s *StatisticsService) SaveOrder(client *model.Client, order *model.HistoryOrder) error {
-	ctx := context.Background()
-	query := `
-		INSERT INTO HistoryOrder (client_name, exchange_name, label, pair, side, type_order,
-								   base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
-								   commission_quote_qty, time_placed)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	if err := s.conn.Exec(ctx, query,
-		client.ClientName, client.ExchangeName, client.Label, client.Pair,
-		order.Side, order.TypeOrder, order.BaseQty, order.Price, order.AlgorithmNamePlaced,
-		order.LowestSellPrc, order.HighestBuyPrc, order.CommissionQuoteQty, order.TimePlaced,
-	); err != nil {
-		return fmt.Errorf("failed to execute insert query for order history: %v", err)
+func (s *StatisticsService) SaveOrder(ctx context.Context, client *model.Client, order *model.HistoryOrder) error {
+	order.ClientName = client.ClientName
+	order.ExchangeName = client.ExchangeName
+	order.Label = client.Label
+	order.Pair = client.Pair
+	stampHistoryOrders(&s.historyIDs, order)
+
+	if s.batcher != nil {
+		return s.batcher.enqueue(ctx, *order)
 	}
+	return s.insertHistoryOrders(ctx, []model.HistoryOrder{*order})
+}
+
+func (s *StatisticsService) SaveOrders(ctx context.Context, orders []*model.HistoryOrder) error {
+	stampHistoryOrders(&s.historyIDs, orders...)
+	historyOrders := make([]model.HistoryOrder, 0, len(orders))
+	for _, order := range orders {
+		historyOrders = append(historyOrders, *order)
+	}
+	return s.insertHistoryOrders(ctx, historyOrders)
+}
+
+// GetCandles reads the per-minute aggregates of the HistoryOrderCandles
+// materialized view and merges them into candles of the requested interval.
+func (s *StatisticsService) GetCandles(ctx context.Context, query *model.CandleQuery) ([]*model.Candle, error) {
+	if err := validateCandleQuery(query); err != nil {
+		return nil, err
+	}
+
+	lower, upper := candleBounds(query)
+	rows, err := s.conn.Query(ctx, `
+		SELECT toStartOfInterval(bucket, toIntervalSecond(?)) AS time,
+			argMinMerge(open), maxMerge(high), minMerge(low), argMaxMerge(close),
+			sumMerge(volume), countMerge(trades)
+		FROM HistoryOrderCandles
+		WHERE exchange_name = ? AND pair = ? AND bucket >= ? AND bucket < ?
+		GROUP BY time
+		HAVING time >= ? AND time < ?
+		ORDER BY time
+	`, int64(query.Interval/time.Second), query.ExchangeName, query.Pair, lower, upper, query.From, query.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for candles: %w", err)
+	}
+	defer rows.Close()
+
+	candles := []*model.Candle{}
+	for rows.Next() {
+		var candle model.Candle
+		if err := rows.Scan(&candle.Time, &candle.Open, &candle.High, &candle.Low, &candle.Close,
+			&candle.Volume, &candle.Trades); err != nil {
+			return nil, fmt.Errorf("failed to scan row for candles: %w", err)
+		}
+		candle.Time = candle.Time.UTC()
+		candles = append(candles, &candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over candle rows: %w", err)
+	}
+
+	return candles, nil
+}
+
+func (s *StatisticsService) AlgorithmActivity(ctx context.Context, filter *model.HistoryFilter) ([]*model.AlgorithmActivity, error) {
+	query, args := algorithmActivityQuery("HistoryOrder", filter)
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for algorithm activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := []*model.AlgorithmActivity{}
+	for rows.Next() {
+		var a model.AlgorithmActivity
+		if err := rows.Scan(&a.AlgorithmNamePlaced, &a.ExchangeName, &a.Pair, &a.Fills,
+			&a.BuyQty, &a.BuyNotional, &a.SellQty, &a.SellNotional, &a.Commission,
+			&a.SlippageBps, &a.SlippageOrders); err != nil {
+			return nil, fmt.Errorf("failed to scan row for algorithm activity: %w", err)
+		}
+		activity = append(activity, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over algorithm activity rows: %w", err)
+	}
+
+	return activity, nil
+}
+
+const clientColumns = "client_name, exchange_name, label, pair"
+
+const clientMatch = "client_name = ? AND exchange_name = ? AND label = ? AND pair = ?"
+
+func (s *StatisticsService) RegisterClient(ctx context.Context, client *model.Client) error {
+	registered, err := s.ClientRegistered(ctx, client)
+	if err != nil {
+		return err
+	}
+	if registered {
+		return ErrClientExists
+	}
+
+	registeredAt := time.Now().UTC().Truncate(time.Millisecond)
+	if err := s.conn.Exec(ctx, "INSERT INTO Client ("+clientColumns+", registered_at) VALUES (?, ?, ?, ?, ?)",
+		client.ClientName, client.ExchangeName, client.Label, client.Pair, registeredAt); err != nil {
+		return fmt.Errorf("failed to insert client: %w", err)
+	}
+	s.metrics.RowsInserted("Client", 1)
+	client.RegisteredAt = registeredAt
 
 	return nil
 }
+
+// ListClients collapses duplicate registrations left by concurrent
+// RegisterClient calls into the earliest one.
+func (s *StatisticsService) ListClients(ctx context.Context, filter *model.Client) ([]*model.Client, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	for _, column := range []struct {
+		name  string
+		value string
+	}{
+		{"client_name", filter.ClientName},
+		{"exchange_name", filter.ExchangeName},
+		{"label", filter.Label},
+		{"pair", filter.Pair},
+	} {
+		if column.value != "" {
+			conditions = append(conditions, column.name+" = ?")
+			args = append(args, column.value)
+		}
+	}
+
+	query := "SELECT " + clientColumns + ", min(registered_at) FROM Client"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " GROUP BY " + clientColumns + " ORDER BY " + clientColumns
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*model.Client{}
+	for rows.Next() {
+		var client model.Client
+		if err := rows.Scan(&client.ClientName, &client.ExchangeName, &client.Label, &client.Pair, &client.RegisteredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row for clients: %w", err)
+		}
+		clients = append(clients, &client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over client rows: %w", err)
+	}
+
+	return clients, nil
+}
+
+func (s *StatisticsService) ClientRegistered(ctx context.Context, client *model.Client) (bool, error) {
+	var count uint64
+	if err := s.conn.QueryRow(ctx, "SELECT count() FROM Client WHERE "+clientMatch,
+		client.ClientName, client.ExchangeName, client.Label, client.Pair).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to query client: %w", err)
+	}
+	return count > 0, nil
+}
+
+// DeleteClient runs a synchronous mutation so the client is gone from
+// ListClients and ClientRegistered once it returns.
+func (s *StatisticsService) DeleteClient(ctx context.Context, client *model.Client) error {
+	registered, err := s.ClientRegistered(ctx, client)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return ErrClientNotFound
+	}
+
+	ctx = withSettings(ctx, clickhouse.Settings{"mutations_sync": 1})
+	if err := s.conn.Exec(ctx, "ALTER TABLE Client DELETE WHERE "+clientMatch,
+		client.ClientName, client.ExchangeName, client.Label, client.Pair); err != nil {
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+
+	return nil
+}
+
+// Flush writes the orders buffered by the batch writer. It is a no-op when
+// batching is disabled.
+func (s *StatisticsService) Flush(ctx context.Context) error {
+	if s.batcher == nil {
+		return nil
+	}
+	return s.batcher.Flush(ctx)
+}
+
+func (s *StatisticsService) insertHistoryOrders(ctx context.Context, orders []model.HistoryOrder) error {
+	batch, err := s.conn.PrepareBatch(ctx, "INSERT INTO HistoryOrder ("+historyColumns+")")
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch for order history: %w", err)
+	}
+
+	for _, order := range orders {
+		if err := batch.Append(
+			order.ID, order.ClientName, order.ExchangeName, order.Label, order.Pair,
+			order.Side, order.TypeOrder, order.BaseQty, order.Price, order.AlgorithmNamePlaced,
+			order.LowestSellPrc, order.HighestBuyPrc, order.CommissionQuoteQty, order.TimePlaced, order.IngestedAt,
+		); err != nil {
+			return fmt.Errorf("failed to append to batch for order history: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send batch for order history: %w", err)
+	}
+	s.metrics.RowsInserted("HistoryOrder", len(orders))
+
+	return nil
+}
+
+func (s *StatisticsService) reportFlush(report FlushReport) {
+	if report.Rows > 0 {
+		s.metrics.ObserveBatch(report.Rows)
+	}
+	if report.Dropped > 0 {
+		if report.Rows > 0 {
+			s.metrics.FlushFailed()
+		}
+		s.metrics.OrdersDropped(report.Dropped)
+		slog.Error("dropped history orders",
+			"rows", report.Dropped, "error", report.Err)
+	} else if report.Err != nil {
+		s.metrics.FlushFailed()
+		slog.Error("failed to flush history orders, will retry",
+			"rows", report.Rows, "duration", report.Duration, "error", report.Err)
+	}
+}
@@ -0,0 +1,103 @@
+package statistic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+const (
+	DefaultHistoryLimit  = 100
+	MaxHistoryLimit      = 1000
+	DefaultSnapshotLimit = 100
+	MaxSnapshotLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// historyCursor points just past the last order of a page. Orders are sorted
+// by (time_placed, id), so the next page starts at the first order after the
+// time and id of the last order, however many orders are inserted before it.
+type historyCursor struct {
+	Time time.Time `json:"t"`
+	ID   int64     `json:"i"`
+}
+
+func (c historyCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeHistoryCursor(s string) (historyCursor, error) {
+	var c historyCursor
+	if s == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil || c.Time.IsZero() {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// after reports whether order comes after the cursor in history order.
+func (c historyCursor) after(order *model.HistoryOrder) bool {
+	if !order.TimePlaced.Equal(c.Time) {
+		return order.TimePlaced.After(c.Time)
+	}
+	return order.ID > c.ID
+}
+
+func historyLimit(limit int) int {
+	return clampLimit(limit, DefaultHistoryLimit, MaxHistoryLimit)
+}
+
+func snapshotLimit(limit int) int {
+	return clampLimit(limit, DefaultSnapshotLimit, MaxSnapshotLimit)
+}
+
+func clampLimit(limit, defaultLimit, maxLimit int) int {
+	switch {
+	case limit <= 0:
+		return defaultLimit
+	case limit > maxLimit:
+		return maxLimit
+	default:
+		return limit
+	}
+}
+
+// historyPage builds a page from up to limit+1 orders read after cursor in
+// history order. The extra order only signals that another page exists.
+func historyPage(orders []*model.HistoryOrder, limit int) *model.HistoryPage {
+	page := &model.HistoryPage{Orders: orders}
+	if len(orders) <= limit {
+		if page.Orders == nil {
+			page.Orders = []*model.HistoryOrder{}
+		}
+		return page
+	}
+
+	page.Orders = orders[:limit]
+	last := page.Orders[limit-1]
+	page.NextCursor = historyCursor{Time: last.TimePlaced, ID: last.ID}.encode()
+	return page
+}
+
+// lessHistoryOrder is the order in which history pages are returned. Ids are
+// unique, so pagination is deterministic.
+func lessHistoryOrder(a, b *model.HistoryOrder) bool {
+	if !a.TimePlaced.Equal(b.TimePlaced) {
+		return a.TimePlaced.Before(b.TimePlaced)
+	}
+	return a.ID < b.ID
+}
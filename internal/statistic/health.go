@@ -0,0 +1,92 @@
+package statistic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/migrate"
+)
+
+// Readiness check statuses.
+const (
+	CheckOK     = "ok"
+	CheckFailed = "failed"
+)
+
+// maxBufferUsage is the share of the write buffer above which the storage
+// reports itself as not ready, so traffic moves elsewhere before enqueues
+// start failing with ErrWriteBufferFull.
+const maxBufferUsage = 0.9
+
+// Check is the outcome of one readiness check of the storage.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newCheck(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Status: CheckFailed, Error: err.Error()}
+	}
+	return Check{Name: name, Status: CheckOK}
+}
+
+// ChecksPassed reports whether none of checks failed.
+func ChecksPassed(checks []Check) bool {
+	for _, check := range checks {
+		if check.Status != CheckOK {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready pings ClickHouse, checks that the schema is migrated at least to the
+// latest migration the service was deployed with and that the write buffer
+// has room left.
+func (s *StatisticsService) Ready(ctx context.Context) []Check {
+	checks := []Check{newCheck("clickhouse", s.conn.Ping(ctx))}
+	if s.expectedMigration > 0 {
+		checks = append(checks, newCheck("migrations", s.checkMigrations(ctx)))
+	}
+	if s.batcher != nil {
+		checks = append(checks, newCheck("write_buffer", s.checkWriteBuffer()))
+	}
+	return checks
+}
+
+func (s *StatisticsService) checkMigrations(ctx context.Context) error {
+	applied, err := migrate.AppliedMigrations(ctx, s.conn)
+	if err != nil {
+		return err
+	}
+	var version uint64
+	for v := range applied {
+		version = max(version, v)
+	}
+	if version < s.expectedMigration {
+		return fmt.Errorf("schema is at version %d, expected %d", version, s.expectedMigration)
+	}
+	return nil
+}
+
+func (s *StatisticsService) checkWriteBuffer() error {
+	if usage := s.batcher.bufferUsage(); usage >= maxBufferUsage {
+		return fmt.Errorf("write buffer is %.0f%% full", usage*100)
+	}
+	return nil
+}
+
+// expectedMigrationVersion returns the latest migration in dir, or 0 when
+// dir is empty and the schema version is not checked.
+func expectedMigrationVersion(dir string) (uint64, error) {
+	if dir == "" {
+		return 0, nil
+	}
+	migrations, err := migrate.LoadMigrations(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return migrate.LatestVersion(migrations), nil
+}
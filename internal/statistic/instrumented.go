@@ -0,0 +1,145 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// instrumentedStatistics records the latency and failures of every call to
+// the wrapped storage, labelled with the method name.
+type instrumentedStatistics struct {
+	next    IStatistics
+	metrics *metrics.Metrics
+}
+
+// Instrument wraps s so its calls are recorded in m.
+func Instrument(s IStatistics, m *metrics.Metrics) IStatistics {
+	return &instrumentedStatistics{next: s, metrics: m}
+}
+
+// observe records a call that started at start. Errors that are an expected
+// answer, such as a missing snapshot, do not count as failures.
+func (s *instrumentedStatistics) observe(method string, start time.Time, err error) {
+	failed := err != nil &&
+		!errors.Is(err, ErrOrderBookNotFound) &&
+		!errors.Is(err, ErrClientExists) &&
+		!errors.Is(err, ErrClientNotFound)
+	s.metrics.ObserveStorageCall(method, time.Since(start), failed)
+}
+
+func (s *instrumentedStatistics) GetOrderBook(ctx context.Context, exchangeName, pair string) (*model.OrderBook, error) {
+	start := time.Now()
+	orderBook, err := s.next.GetOrderBook(ctx, exchangeName, pair)
+	s.observe("GetOrderBook", start, err)
+	return orderBook, err
+}
+
+func (s *instrumentedStatistics) GetOrderBookSnapshot(ctx context.Context, exchangeName, pair string, at time.Time) (*model.OrderBook, error) {
+	start := time.Now()
+	orderBook, err := s.next.GetOrderBookSnapshot(ctx, exchangeName, pair, at)
+	s.observe("GetOrderBookSnapshot", start, err)
+	return orderBook, err
+}
+
+func (s *instrumentedStatistics) ListOrderBookSnapshots(ctx context.Context, exchangeName, pair string, from, to time.Time, limit int) ([]*model.OrderBook, error) {
+	start := time.Now()
+	orderBooks, err := s.next.ListOrderBookSnapshots(ctx, exchangeName, pair, from, to, limit)
+	s.observe("ListOrderBookSnapshots", start, err)
+	return orderBooks, err
+}
+
+func (s *instrumentedStatistics) SaveOrderBook(ctx context.Context, orderBook *model.OrderBook) error {
+	start := time.Now()
+	err := s.next.SaveOrderBook(ctx, orderBook)
+	s.observe("SaveOrderBook", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) GetOrderHistory(ctx context.Context, client *model.Client) ([]*model.HistoryOrder, error) {
+	start := time.Now()
+	orders, err := s.next.GetOrderHistory(ctx, client)
+	s.observe("GetOrderHistory", start, err)
+	return orders, err
+}
+
+func (s *instrumentedStatistics) QueryOrderHistory(ctx context.Context, filter *model.HistoryFilter) (*model.HistoryPage, error) {
+	start := time.Now()
+	page, err := s.next.QueryOrderHistory(ctx, filter)
+	s.observe("QueryOrderHistory", start, err)
+	return page, err
+}
+
+func (s *instrumentedStatistics) SaveOrder(ctx context.Context, client *model.Client, order *model.HistoryOrder) error {
+	start := time.Now()
+	err := s.next.SaveOrder(ctx, client, order)
+	s.observe("SaveOrder", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) SaveOrders(ctx context.Context, orders []*model.HistoryOrder) error {
+	start := time.Now()
+	err := s.next.SaveOrders(ctx, orders)
+	s.observe("SaveOrders", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) GetCandles(ctx context.Context, query *model.CandleQuery) ([]*model.Candle, error) {
+	start := time.Now()
+	candles, err := s.next.GetCandles(ctx, query)
+	s.observe("GetCandles", start, err)
+	return candles, err
+}
+
+func (s *instrumentedStatistics) AlgorithmActivity(ctx context.Context, filter *model.HistoryFilter) ([]*model.AlgorithmActivity, error) {
+	start := time.Now()
+	activity, err := s.next.AlgorithmActivity(ctx, filter)
+	s.observe("AlgorithmActivity", start, err)
+	return activity, err
+}
+
+func (s *instrumentedStatistics) RegisterClient(ctx context.Context, client *model.Client) error {
+	start := time.Now()
+	err := s.next.RegisterClient(ctx, client)
+	s.observe("RegisterClient", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) ListClients(ctx context.Context, filter *model.Client) ([]*model.Client, error) {
+	start := time.Now()
+	clients, err := s.next.ListClients(ctx, filter)
+	s.observe("ListClients", start, err)
+	return clients, err
+}
+
+func (s *instrumentedStatistics) ClientRegistered(ctx context.Context, client *model.Client) (bool, error) {
+	start := time.Now()
+	registered, err := s.next.ClientRegistered(ctx, client)
+	s.observe("ClientRegistered", start, err)
+	return registered, err
+}
+
+func (s *instrumentedStatistics) DeleteClient(ctx context.Context, client *model.Client) error {
+	start := time.Now()
+	err := s.next.DeleteClient(ctx, client)
+	s.observe("DeleteClient", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) Flush(ctx context.Context) error {
+	start := time.Now()
+	err := s.next.Flush(ctx)
+	s.observe("Flush", start, err)
+	return err
+}
+
+func (s *instrumentedStatistics) Ready(ctx context.Context) []Check {
+	return s.next.Ready(ctx)
+}
+
+func (s *instrumentedStatistics) Close() error {
+	return s.next.Close()
+}
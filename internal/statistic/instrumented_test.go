@@ -0,0 +1,13 @@
+package statistic
+
+import (
+	"testing"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+)
+
+func TestInstrumentedStatistics(t *testing.T) {
+	testStatistics(t, func(t *testing.T) IStatistics {
+		return Instrument(NewMemoryStatistics(), metrics.New())
+	})
+}
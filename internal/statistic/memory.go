@@ -0,0 +1,399 @@
+package statistic
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// MemoryStatistics keeps everything in process memory. It mirrors the
+// behaviour of StatisticsService and is meant for tests and local runs.
+// Calls never block, so ctx is only checked when a call starts.
+type MemoryStatistics struct {
+	mu           sync.RWMutex
+	orderBooks   []model.OrderBook
+	orderBookIDs idSequence
+	history      []model.HistoryOrder
+	historyIDs   idSequence
+	clients      []model.Client
+}
+
+func NewMemoryStatistics() *MemoryStatistics {
+	return &MemoryStatistics{}
+}
+
+// Flush is a no-op: orders are stored as soon as they are saved.
+func (m *MemoryStatistics) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Ready has nothing to check: the memory backend is always ready.
+func (m *MemoryStatistics) Ready(ctx context.Context) []Check {
+	return nil
+}
+
+func (m *MemoryStatistics) Close() error {
+	return nil
+}
+
+func (m *MemoryStatistics) GetOrderBook(ctx context.Context, exchangeName, pair string) (*model.OrderBook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orderBook := &model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+		Asks:     []model.DepthOrder{},
+		Bids:     []model.DepthOrder{},
+	}
+	for _, book := range m.orderBooks {
+		if book.Exchange != exchangeName || book.Pair != pair {
+			continue
+		}
+		orderBook.Asks = append(orderBook.Asks, book.Asks...)
+		orderBook.Bids = append(orderBook.Bids, book.Bids...)
+	}
+
+	return orderBook, nil
+}
+
+func (m *MemoryStatistics) GetOrderBookSnapshot(ctx context.Context, exchangeName, pair string, at time.Time) (*model.OrderBook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *model.OrderBook
+	for i := range m.orderBooks {
+		book := &m.orderBooks[i]
+		if book.Exchange != exchangeName || book.Pair != pair {
+			continue
+		}
+		if !at.IsZero() && book.SnapshotTime.After(at) {
+			continue
+		}
+		if latest == nil || book.SnapshotTime.After(latest.SnapshotTime) ||
+			(book.SnapshotTime.Equal(latest.SnapshotTime) && book.ID > latest.ID) {
+			latest = book
+		}
+	}
+	if latest == nil {
+		return nil, ErrOrderBookNotFound
+	}
+
+	return copyOrderBook(latest), nil
+}
+
+func (m *MemoryStatistics) ListOrderBookSnapshots(ctx context.Context, exchangeName, pair string, from, to time.Time, limit int) ([]*model.OrderBook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orderBooks := []*model.OrderBook{}
+	for i := range m.orderBooks {
+		book := &m.orderBooks[i]
+		if book.Exchange != exchangeName || book.Pair != pair {
+			continue
+		}
+		if (!from.IsZero() && book.SnapshotTime.Before(from)) || (!to.IsZero() && !book.SnapshotTime.Before(to)) {
+			continue
+		}
+		orderBooks = append(orderBooks, copyOrderBook(book))
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		if !orderBooks[i].SnapshotTime.Equal(orderBooks[j].SnapshotTime) {
+			return orderBooks[i].SnapshotTime.Before(orderBooks[j].SnapshotTime)
+		}
+		return orderBooks[i].ID < orderBooks[j].ID
+	})
+
+	return orderBooks[:min(snapshotLimit(limit), len(orderBooks))], nil
+}
+
+func (m *MemoryStatistics) SaveOrderBook(ctx context.Context, orderBook *model.OrderBook) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orderBook.SnapshotTime = time.Now().UTC().Truncate(time.Millisecond)
+	orderBook.ID = m.orderBookIDs.next(orderBook.SnapshotTime)
+	m.orderBooks = append(m.orderBooks, *copyOrderBook(orderBook))
+
+	return nil
+}
+
+func copyOrderBook(orderBook *model.OrderBook) *model.OrderBook {
+	book := *orderBook
+	book.Asks = append([]model.DepthOrder{}, orderBook.Asks...)
+	book.Bids = append([]model.DepthOrder{}, orderBook.Bids...)
+	return &book
+}
+
+func (m *MemoryStatistics) GetOrderHistory(ctx context.Context, client *model.Client) ([]*model.HistoryOrder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orderHistory []*model.HistoryOrder
+	for _, order := range m.history {
+		if order.ClientName != client.ClientName || order.ExchangeName != client.ExchangeName ||
+			order.Label != client.Label || order.Pair != client.Pair {
+			continue
+		}
+		historyOrder := order
+		orderHistory = append(orderHistory, &historyOrder)
+	}
+
+	return orderHistory, nil
+}
+
+func (m *MemoryStatistics) QueryOrderHistory(ctx context.Context, filter *model.HistoryFilter) (*model.HistoryPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := decodeHistoryCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := historyLimit(filter.Limit)
+
+	m.mu.RLock()
+	var orders []*model.HistoryOrder
+	for _, order := range m.history {
+		if matchesHistoryFilter(&order, filter) && (cursor.Time.IsZero() || cursor.after(&order)) {
+			historyOrder := order
+			orders = append(orders, &historyOrder)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(orders, func(i, j int) bool {
+		return lessHistoryOrder(orders[i], orders[j])
+	})
+	orders = orders[:min(limit+1, len(orders))]
+
+	return historyPage(orders, limit), nil
+}
+
+func matchesHistoryFilter(order *model.HistoryOrder, filter *model.HistoryFilter) bool {
+	for _, field := range [][2]string{
+		{filter.ClientName, order.ClientName},
+		{filter.ExchangeName, order.ExchangeName},
+		{filter.Label, order.Label},
+		{filter.Pair, order.Pair},
+		{filter.Side, order.Side},
+		{filter.TypeOrder, order.TypeOrder},
+		{filter.AlgorithmNamePlaced, order.AlgorithmNamePlaced},
+	} {
+		if field[0] != "" && field[0] != field[1] {
+			return false
+		}
+	}
+	if !filter.From.IsZero() && order.TimePlaced.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !order.TimePlaced.Before(filter.To) {
+		return false
+	}
+	return true
+}
+
+func (m *MemoryStatistics) SaveOrder(ctx context.Context, client *model.Client, order *model.HistoryOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// As in StatisticsService, the client tuple is taken from client rather than order.
+	order.ClientName = client.ClientName
+	order.ExchangeName = client.ExchangeName
+	order.Label = client.Label
+	order.Pair = client.Pair
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stampHistoryOrders(&m.historyIDs, order)
+	m.history = append(m.history, *order)
+
+	return nil
+}
+
+func (m *MemoryStatistics) SaveOrders(ctx context.Context, orders []*model.HistoryOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stampHistoryOrders(&m.historyIDs, orders...)
+	for _, order := range orders {
+		m.history = append(m.history, *order)
+	}
+
+	return nil
+}
+
+func (m *MemoryStatistics) GetCandles(ctx context.Context, query *model.CandleQuery) ([]*model.Candle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := validateCandleQuery(query); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []*model.HistoryOrder
+	for i := range m.history {
+		if m.history[i].ExchangeName == query.ExchangeName && m.history[i].Pair == query.Pair {
+			orders = append(orders, &m.history[i])
+		}
+	}
+
+	return aggregateCandles(orders, query), nil
+}
+
+func (m *MemoryStatistics) AlgorithmActivity(ctx context.Context, filter *model.HistoryFilter) ([]*model.AlgorithmActivity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []*model.HistoryOrder
+	for i := range m.history {
+		if matchesHistoryFilter(&m.history[i], filter) {
+			orders = append(orders, &m.history[i])
+		}
+	}
+
+	return aggregateAlgorithmActivity(orders), nil
+}
+
+func (m *MemoryStatistics) RegisterClient(ctx context.Context, client *model.Client) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, registered := range m.clients {
+		if sameClient(&registered, client) {
+			return ErrClientExists
+		}
+	}
+	client.RegisteredAt = time.Now().UTC().Truncate(time.Millisecond)
+	m.clients = append(m.clients, *client)
+
+	return nil
+}
+
+func (m *MemoryStatistics) ListClients(ctx context.Context, filter *model.Client) ([]*model.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := []*model.Client{}
+	for i := range m.clients {
+		if matchesClientFilter(&m.clients[i], filter) {
+			client := m.clients[i]
+			clients = append(clients, &client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return lessClient(clients[i], clients[j])
+	})
+
+	return clients, nil
+}
+
+func (m *MemoryStatistics) ClientRegistered(ctx context.Context, client *model.Client) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range m.clients {
+		if sameClient(&m.clients[i], client) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *MemoryStatistics) DeleteClient(ctx context.Context, client *model.Client) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.clients {
+		if sameClient(&m.clients[i], client) {
+			m.clients = append(m.clients[:i], m.clients[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrClientNotFound
+}
+
+func sameClient(a, b *model.Client) bool {
+	return a.ClientName == b.ClientName && a.ExchangeName == b.ExchangeName &&
+		a.Label == b.Label && a.Pair == b.Pair
+}
+
+func matchesClientFilter(client, filter *model.Client) bool {
+	for _, field := range [][2]string{
+		{filter.ClientName, client.ClientName},
+		{filter.ExchangeName, client.ExchangeName},
+		{filter.Label, client.Label},
+		{filter.Pair, client.Pair},
+	} {
+		if field[0] != "" && field[0] != field[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// lessClient matches the ORDER BY of StatisticsService.ListClients.
+func lessClient(a, b *model.Client) bool {
+	for _, pair := range [][2]string{
+		{a.ClientName, b.ClientName},
+		{a.ExchangeName, b.ExchangeName},
+		{a.Label, b.Label},
+		{a.Pair, b.Pair},
+	} {
+		if pair[0] != pair[1] {
+			return pair[0] < pair[1]
+		}
+	}
+	return false
+}
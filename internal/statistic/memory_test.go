@@ -0,0 +1,9 @@
+package statistic
+
+import "testing"
+
+func TestMemoryStatistics(t *testing.T) {
+	testStatistics(t, func(t *testing.T) IStatistics {
+		return NewMemoryStatistics()
+	})
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS client;
+DROP TABLE IF EXISTS history_order;
+DROP TABLE IF EXISTS order_book;
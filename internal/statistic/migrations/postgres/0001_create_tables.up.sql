@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS order_book (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    exchange TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    snapshot_time TIMESTAMPTZ NOT NULL,
+    -- Levels are [price, base_qty] pairs, as in the ClickHouse schema.
+    asks JSONB NOT NULL,
+    bids JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_book_market_time_idx ON order_book (exchange, pair, snapshot_time, id);
+
+CREATE TABLE IF NOT EXISTS history_order (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    client_name TEXT NOT NULL,
+    exchange_name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    side TEXT NOT NULL,
+    type_order TEXT NOT NULL,
+    base_qty DOUBLE PRECISION NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    algorithm_name_placed TEXT NOT NULL,
+    lowest_sell_prc DOUBLE PRECISION NOT NULL,
+    highest_buy_prc DOUBLE PRECISION NOT NULL,
+    commission_quote_qty DOUBLE PRECISION NOT NULL,
+    time_placed TIMESTAMPTZ NOT NULL,
+    ingested_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_order_client_time_idx
+    ON history_order (client_name, exchange_name, label, pair, time_placed);
+CREATE INDEX IF NOT EXISTS history_order_market_time_idx
+    ON history_order (exchange_name, pair, time_placed);
+CREATE INDEX IF NOT EXISTS history_order_time_idx ON history_order (time_placed);
+
+CREATE TABLE IF NOT EXISTS client (
+    client_name TEXT NOT NULL,
+    exchange_name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    registered_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (client_name, exchange_name, label, pair)
+);
@@ -0,0 +1,45 @@
+-- Times are stored as UTC text, which sorts in time order.
+CREATE TABLE IF NOT EXISTS order_book (
+    id INTEGER PRIMARY KEY,
+    exchange TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    snapshot_time TIMESTAMP NOT NULL,
+    -- Levels are [price, base_qty] pairs, as in the ClickHouse schema.
+    asks TEXT NOT NULL,
+    bids TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_book_market_time_idx ON order_book (exchange, pair, snapshot_time, id);
+
+CREATE TABLE IF NOT EXISTS history_order (
+    id INTEGER PRIMARY KEY,
+    client_name TEXT NOT NULL,
+    exchange_name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    side TEXT NOT NULL,
+    type_order TEXT NOT NULL,
+    base_qty REAL NOT NULL,
+    price REAL NOT NULL,
+    algorithm_name_placed TEXT NOT NULL,
+    lowest_sell_prc REAL NOT NULL,
+    highest_buy_prc REAL NOT NULL,
+    commission_quote_qty REAL NOT NULL,
+    time_placed TIMESTAMP NOT NULL,
+    ingested_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_order_client_time_idx
+    ON history_order (client_name, exchange_name, label, pair, time_placed);
+CREATE INDEX IF NOT EXISTS history_order_market_time_idx
+    ON history_order (exchange_name, pair, time_placed);
+CREATE INDEX IF NOT EXISTS history_order_time_idx ON history_order (time_placed);
+
+CREATE TABLE IF NOT EXISTS client (
+    client_name TEXT NOT NULL,
+    exchange_name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    registered_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (client_name, exchange_name, label, pair)
+);
@@ -0,0 +1,70 @@
+package statistic
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	// Registers the "pgx" database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationLock is the advisory lock key held while migrating, so
+// instances started together do not apply the same migration twice.
+const postgresMigrationLock = 0x5354415453
+
+// NewPostgresStatistics connects to the PostgreSQL database of cfg.DSN and
+// applies the embedded migrations that are still pending.
+func NewPostgresStatistics(cfg config.Postgres, m *metrics.Metrics) (*SQLStatistics, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	statistics, err := newSQLStatistics(db, sqlDialect{
+		name:           DriverPostgres,
+		rebind:         numberedPlaceholders,
+		migrations:     migrations,
+		lockMigrations: fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", postgresMigrationLock),
+	}, m)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return statistics, nil
+}
+
+// numberedPlaceholders rewrites the ? placeholders of query to $1, $2, ...
+// The queries of SQLStatistics contain no literal question marks.
+func numberedPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
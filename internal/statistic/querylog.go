@@ -0,0 +1,67 @@
+package statistic
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/logging"
+)
+
+type settingsKey struct{}
+
+// withSettings adds ClickHouse settings to the queries run with ctx through
+// a requestConn. Unlike clickhouse.WithSettings, they are merged with the
+// log_comment the connection adds instead of being replaced by it.
+func withSettings(ctx context.Context, settings clickhouse.Settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, settings)
+}
+
+// requestConn sets the log_comment of every query to the request ID of its
+// context, so the queries of a request can be found in system.query_log.
+type requestConn struct {
+	driver.Conn
+}
+
+func (c requestConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return c.Conn.Select(queryContext(ctx), dest, query, args...)
+}
+
+func (c requestConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	return c.Conn.Query(queryContext(ctx), query, args...)
+}
+
+func (c requestConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return c.Conn.QueryRow(queryContext(ctx), query, args...)
+}
+
+func (c requestConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return c.Conn.PrepareBatch(queryContext(ctx), query, opts...)
+}
+
+func (c requestConn) Exec(ctx context.Context, query string, args ...any) error {
+	return c.Conn.Exec(queryContext(ctx), query, args...)
+}
+
+func (c requestConn) AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error {
+	return c.Conn.AsyncInsert(queryContext(ctx), query, wait, args...)
+}
+
+// queryContext returns ctx with the settings added by withSettings and the
+// request ID as log_comment.
+func queryContext(ctx context.Context) context.Context {
+	extra, _ := ctx.Value(settingsKey{}).(clickhouse.Settings)
+	id := logging.RequestID(ctx)
+	if id == "" && extra == nil {
+		return ctx
+	}
+
+	settings := make(clickhouse.Settings, len(extra)+1)
+	for name, value := range extra {
+		settings[name] = value
+	}
+	if id != "" {
+		settings["log_comment"] = id
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+}
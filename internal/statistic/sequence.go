@@ -0,0 +1,66 @@
+package statistic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// Ids are laid out like snowflake ids: milliseconds since idEpoch, then the
+// instance id, then a sequence number within the millisecond. Instances with
+// distinct instance ids never hand out the same id.
+const (
+	instanceBits = 10
+	sequenceBits = 12
+
+	maxInstanceID = 1<<instanceBits - 1
+	maxSequence   = 1<<sequenceBits - 1
+)
+
+var idEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// idSequence hands out monotonically increasing ids. When the clock does not
+// move forward the sequence number is bumped, and once it runs out the next
+// millisecond is borrowed, so ids stay increasing across restarts and clock
+// steps. The zero value hands out ids of instance 0.
+type idSequence struct {
+	mu       sync.Mutex
+	instance int64
+	lastMs   int64
+	seq      int64
+}
+
+// seed makes sure the sequence never returns an id at or below last.
+func (s *idSequence) seed(last int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ms := last >> (instanceBits + sequenceBits); ms >= s.lastMs {
+		s.lastMs = ms
+		s.seq = maxSequence
+	}
+}
+
+func (s *idSequence) next(now time.Time) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := now.Sub(idEpoch).Milliseconds()
+	switch {
+	case ms > s.lastMs:
+		s.lastMs, s.seq = ms, 0
+	case s.seq < maxSequence:
+		s.seq++
+	default:
+		s.lastMs, s.seq = s.lastMs+1, 0
+	}
+	return s.lastMs<<(instanceBits+sequenceBits) | s.instance<<sequenceBits | s.seq
+}
+
+// stampHistoryOrders assigns ids from seq and the ingest time to orders.
+func stampHistoryOrders(seq *idSequence, orders ...*model.HistoryOrder) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, order := range orders {
+		order.ID = seq.next(now)
+		order.IngestedAt = now
+	}
+}
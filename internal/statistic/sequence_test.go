@@ -0,0 +1,42 @@
+package statistic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIDSequence_DistinctInstances(t *testing.T) {
+	now := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	seen := make(map[int64]bool)
+	for _, instance := range []int64{1, 2} {
+		seq := &idSequence{instance: instance}
+		seq.seed(now.Sub(idEpoch).Milliseconds() << (instanceBits + sequenceBits))
+
+		var last int64
+		// More ids than fit in one millisecond, all at the same time.
+		for i := 0; i < 2*maxSequence; i++ {
+			id := seq.next(now)
+			if id <= last {
+				t.Fatalf("expected increasing ids, but got %d after %d", id, last)
+			}
+			if got := id >> sequenceBits & maxInstanceID; got != instance {
+				t.Fatalf("expected instance %d in id %d, but got %d", instance, id, got)
+			}
+			if seen[id] {
+				t.Fatalf("id %d was handed out twice", id)
+			}
+			seen[id] = true
+			last = id
+		}
+	}
+}
+
+func TestIDSequence_Seed(t *testing.T) {
+	now := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	seq := &idSequence{instance: 3}
+	last := now.Add(time.Hour).Sub(idEpoch).Milliseconds()<<(instanceBits+sequenceBits) | 5<<sequenceBits | maxSequence
+	seq.seed(last)
+	if id := seq.next(now); id <= last {
+		t.Errorf("expected an id after %d when the clock is behind, but got %d", last, id)
+	}
+}
@@ -0,0 +1,608 @@
+package statistic
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/migrate"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// sqlDialect holds what differs between the database/sql backends.
+type sqlDialect struct {
+	// name is the storage driver, used in errors and readiness checks.
+	name string
+	// rebind rewrites the ? placeholders of a query for the database.
+	rebind func(query string) string
+	// migrations holds the versioned schema of the backend.
+	migrations fs.FS
+	// lockMigrations, when set, runs first in the migration transaction to
+	// keep concurrent instances from migrating at the same time.
+	lockMigrations string
+}
+
+// SQLStatistics stores statistics in a relational database through
+// database/sql. Order book levels are kept as JSON arrays of [price,
+// base_qty] pairs and candles are aggregated from the order history on read.
+// Ids are generated by the database, so several instances may share it.
+type SQLStatistics struct {
+	db      *sql.DB
+	dialect sqlDialect
+	// metrics counts inserted rows; it may be nil.
+	metrics *metrics.Metrics
+}
+
+// historyInsertColumns are the columns of historyColumns set on insert; the
+// id is generated by the database.
+const historyInsertColumns = `client_name, exchange_name, label, pair, side, type_order,
+	base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
+	commission_quote_qty, time_placed, ingested_at`
+
+// newSQLStatistics migrates the schema of db.
+func newSQLStatistics(db *sql.DB, dialect sqlDialect, m *metrics.Metrics) (*SQLStatistics, error) {
+	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping %s: %w", dialect.name, err)
+	}
+	if err := migrateSQL(ctx, db, dialect); err != nil {
+		return nil, fmt.Errorf("failed to migrate %s schema: %w", dialect.name, err)
+	}
+
+	return &SQLStatistics{
+		db:      db,
+		dialect: dialect,
+		metrics: m,
+	}, nil
+}
+
+func (s *SQLStatistics) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (s *SQLStatistics) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (s *SQLStatistics) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), sqlArgs(args)...)
+}
+
+// sqlArgs stores every time in UTC, so times compare the same way whatever
+// location the caller used.
+func sqlArgs(args []any) []any {
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC()
+		}
+	}
+	return args
+}
+
+func (s *SQLStatistics) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *SQLStatistics) Ready(ctx context.Context) []Check {
+	return []Check{newCheck(s.dialect.name, s.db.PingContext(ctx))}
+}
+
+func (s *SQLStatistics) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStatistics) GetOrderBook(ctx context.Context, exchangeName, pair string) (*model.OrderBook, error) {
+	rows, err := s.query(ctx, `
+		SELECT asks, bids
+		FROM order_book
+		WHERE exchange = ? AND pair = ?
+		ORDER BY snapshot_time, id
+	`, exchangeName, pair)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for order book: %w", err)
+	}
+	defer rows.Close()
+
+	orderBook := &model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+		Asks:     []model.DepthOrder{},
+		Bids:     []model.DepthOrder{},
+	}
+	for rows.Next() {
+		var asks, bids []byte
+		if err := rows.Scan(&asks, &bids); err != nil {
+			return nil, fmt.Errorf("failed to scan row for order book: %w", err)
+		}
+		askLevels, bidLevels, err := decodeDepthLevels(asks, bids)
+		if err != nil {
+			return nil, err
+		}
+		orderBook.Asks = append(orderBook.Asks, askLevels...)
+		orderBook.Bids = append(orderBook.Bids, bidLevels...)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over order book rows: %w", err)
+	}
+
+	return orderBook, nil
+}
+
+func (s *SQLStatistics) GetOrderBookSnapshot(ctx context.Context, exchangeName, pair string, at time.Time) (*model.OrderBook, error) {
+	query := `
+		SELECT id, snapshot_time, asks, bids
+		FROM order_book
+		WHERE exchange = ? AND pair = ?
+	`
+	args := []any{exchangeName, pair}
+	if !at.IsZero() {
+		query += " AND snapshot_time <= ?"
+		args = append(args, at)
+	}
+	query += " ORDER BY snapshot_time DESC, id DESC LIMIT 1"
+
+	orderBook := &model.OrderBook{
+		Exchange: exchangeName,
+		Pair:     pair,
+	}
+	var asks, bids []byte
+	err := s.queryRow(ctx, query, args...).Scan(&orderBook.ID, &orderBook.SnapshotTime, &asks, &bids)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderBookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order book snapshot: %w", err)
+	}
+	orderBook.SnapshotTime = orderBook.SnapshotTime.UTC()
+	if orderBook.Asks, orderBook.Bids, err = decodeDepthLevels(asks, bids); err != nil {
+		return nil, err
+	}
+
+	return orderBook, nil
+}
+
+func (s *SQLStatistics) ListOrderBookSnapshots(ctx context.Context, exchangeName, pair string, from, to time.Time, limit int) ([]*model.OrderBook, error) {
+	query := `
+		SELECT id, snapshot_time, asks, bids
+		FROM order_book
+		WHERE exchange = ? AND pair = ?
+	`
+	args := []any{exchangeName, pair}
+	if !from.IsZero() {
+		query += " AND snapshot_time >= ?"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query += " AND snapshot_time < ?"
+		args = append(args, to)
+	}
+	query += fmt.Sprintf(" ORDER BY snapshot_time, id LIMIT %d", snapshotLimit(limit))
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for order book snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	orderBooks := []*model.OrderBook{}
+	for rows.Next() {
+		orderBook := &model.OrderBook{
+			Exchange: exchangeName,
+			Pair:     pair,
+		}
+		var asks, bids []byte
+		if err := rows.Scan(&orderBook.ID, &orderBook.SnapshotTime, &asks, &bids); err != nil {
+			return nil, fmt.Errorf("failed to scan row for order book snapshots: %w", err)
+		}
+		orderBook.SnapshotTime = orderBook.SnapshotTime.UTC()
+		if orderBook.Asks, orderBook.Bids, err = decodeDepthLevels(asks, bids); err != nil {
+			return nil, err
+		}
+		orderBooks = append(orderBooks, orderBook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over order book snapshot rows: %w", err)
+	}
+
+	return orderBooks, nil
+}
+
+func (s *SQLStatistics) SaveOrderBook(ctx context.Context, orderBook *model.OrderBook) error {
+	asks, err := json.Marshal(depthTuples(orderBook.Asks))
+	if err != nil {
+		return fmt.Errorf("failed to encode asks: %w", err)
+	}
+	bids, err := json.Marshal(depthTuples(orderBook.Bids))
+	if err != nil {
+		return fmt.Errorf("failed to encode bids: %w", err)
+	}
+
+	snapshotTime := time.Now().UTC().Truncate(time.Millisecond)
+	var id int64
+	if err := s.queryRow(ctx, "INSERT INTO order_book (exchange, pair, snapshot_time, asks, bids) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		orderBook.Exchange, orderBook.Pair, snapshotTime, string(asks), string(bids)).Scan(&id); err != nil {
+		return fmt.Errorf("failed to insert order book: %w", err)
+	}
+	s.metrics.RowsInserted("order_book", 1)
+	orderBook.ID = id
+	orderBook.SnapshotTime = snapshotTime
+
+	return nil
+}
+
+func decodeDepthLevels(asks, bids []byte) ([]model.DepthOrder, []model.DepthOrder, error) {
+	var askTuples, bidTuples [][]float64
+	if err := json.Unmarshal(asks, &askTuples); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode asks: %w", err)
+	}
+	if err := json.Unmarshal(bids, &bidTuples); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode bids: %w", err)
+	}
+	return depthOrders(askTuples), depthOrders(bidTuples), nil
+}
+
+func (s *SQLStatistics) GetOrderHistory(ctx context.Context, client *model.Client) ([]*model.HistoryOrder, error) {
+	rows, err := s.query(ctx, `
+		SELECT `+historyColumns+`
+		FROM history_order
+		WHERE `+clientMatch+`
+		ORDER BY `+historyOrderBy,
+		client.ClientName, client.ExchangeName, client.Label, client.Pair)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for order history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSQLHistoryOrders(rows)
+}
+
+func (s *SQLStatistics) QueryOrderHistory(ctx context.Context, filter *model.HistoryFilter) (*model.HistoryPage, error) {
+	cursor, err := decodeHistoryCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := historyLimit(filter.Limit)
+
+	conditions, args := historyConditions(filter)
+	if !cursor.Time.IsZero() {
+		conditions = append(conditions, "(time_placed, id) > (?, ?)")
+		args = append(args, cursor.Time, cursor.ID)
+	}
+
+	query := "SELECT " + historyColumns + " FROM history_order"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", historyOrderBy, limit+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for order history: %w", err)
+	}
+	defer rows.Close()
+
+	orders, err := scanSQLHistoryOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return historyPage(orders, limit), nil
+}
+
+func scanSQLHistoryOrders(rows *sql.Rows) ([]*model.HistoryOrder, error) {
+	var orderHistory []*model.HistoryOrder
+	for rows.Next() {
+		var historyOrder model.HistoryOrder
+		if err := rows.Scan(
+			&historyOrder.ID, &historyOrder.ClientName, &historyOrder.ExchangeName, &historyOrder.Label,
+			&historyOrder.Pair, &historyOrder.Side, &historyOrder.TypeOrder,
+			&historyOrder.BaseQty, &historyOrder.Price, &historyOrder.AlgorithmNamePlaced,
+			&historyOrder.LowestSellPrc, &historyOrder.HighestBuyPrc, &historyOrder.CommissionQuoteQty,
+			&historyOrder.TimePlaced, &historyOrder.IngestedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row for order history: %w", err)
+		}
+		historyOrder.TimePlaced = historyOrder.TimePlaced.UTC()
+		historyOrder.IngestedAt = historyOrder.IngestedAt.UTC()
+		orderHistory = append(orderHistory, &historyOrder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over order history rows: %w", err)
+	}
+
+	return orderHistory, nil
+}
+
+func (s *SQLStatistics) SaveOrder(ctx context.Context, client *model.Client, order *model.HistoryOrder) error {
+	order.ClientName = client.ClientName
+	order.ExchangeName = client.ExchangeName
+	order.Label = client.Label
+	order.Pair = client.Pair
+	return s.SaveOrders(ctx, []*model.HistoryOrder{order})
+}
+
+// SaveOrders inserts orders in one transaction, so either all of them are
+// stored or none. The ids are set once the transaction is committed.
+func (s *SQLStatistics) SaveOrders(ctx context.Context, orders []*model.HistoryOrder) error {
+	ingestedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for order history: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.dialect.rebind(
+		"INSERT INTO history_order ("+historyInsertColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert for order history: %w", err)
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, len(orders))
+	for i, order := range orders {
+		if err := stmt.QueryRowContext(ctx, sqlArgs([]any{
+			order.ClientName, order.ExchangeName, order.Label, order.Pair,
+			order.Side, order.TypeOrder, order.BaseQty, order.Price, order.AlgorithmNamePlaced,
+			order.LowestSellPrc, order.HighestBuyPrc, order.CommissionQuoteQty, order.TimePlaced, ingestedAt,
+		})...).Scan(&ids[i]); err != nil {
+			return fmt.Errorf("failed to insert order history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order history: %w", err)
+	}
+	for i, order := range orders {
+		order.ID = ids[i]
+		order.IngestedAt = ingestedAt
+	}
+	s.metrics.RowsInserted("history_order", len(orders))
+
+	return nil
+}
+
+// GetCandles reads the orders of the candle range and aggregates them like
+// the memory backend; the indexed range scan keeps this cheap for the
+// intervals a candle query may span.
+func (s *SQLStatistics) GetCandles(ctx context.Context, query *model.CandleQuery) ([]*model.Candle, error) {
+	if err := validateCandleQuery(query); err != nil {
+		return nil, err
+	}
+
+	lower, upper := candleBounds(query)
+	rows, err := s.query(ctx, `
+		SELECT time_placed, price, base_qty
+		FROM history_order
+		WHERE exchange_name = ? AND pair = ? AND time_placed >= ? AND time_placed < ?
+		ORDER BY time_placed, id
+	`, query.ExchangeName, query.Pair, lower, upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for candles: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.HistoryOrder
+	for rows.Next() {
+		var order model.HistoryOrder
+		if err := rows.Scan(&order.TimePlaced, &order.Price, &order.BaseQty); err != nil {
+			return nil, fmt.Errorf("failed to scan row for candles: %w", err)
+		}
+		order.TimePlaced = order.TimePlaced.UTC()
+		orders = append(orders, &order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over candle rows: %w", err)
+	}
+
+	return aggregateCandles(orders, query), nil
+}
+
+func (s *SQLStatistics) AlgorithmActivity(ctx context.Context, filter *model.HistoryFilter) ([]*model.AlgorithmActivity, error) {
+	query, args := algorithmActivityQuery("history_order", filter)
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for algorithm activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := []*model.AlgorithmActivity{}
+	for rows.Next() {
+		var a model.AlgorithmActivity
+		if err := rows.Scan(&a.AlgorithmNamePlaced, &a.ExchangeName, &a.Pair, &a.Fills,
+			&a.BuyQty, &a.BuyNotional, &a.SellQty, &a.SellNotional, &a.Commission,
+			&a.SlippageBps, &a.SlippageOrders); err != nil {
+			return nil, fmt.Errorf("failed to scan row for algorithm activity: %w", err)
+		}
+		activity = append(activity, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over algorithm activity rows: %w", err)
+	}
+
+	return activity, nil
+}
+
+func (s *SQLStatistics) RegisterClient(ctx context.Context, client *model.Client) error {
+	registeredAt := time.Now().UTC().Truncate(time.Millisecond)
+	result, err := s.exec(ctx, "INSERT INTO client ("+clientColumns+", registered_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		client.ClientName, client.ExchangeName, client.Label, client.Pair, registeredAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert client: %w", err)
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to insert client: %w", err)
+	} else if inserted == 0 {
+		return ErrClientExists
+	}
+	s.metrics.RowsInserted("client", 1)
+	client.RegisteredAt = registeredAt
+
+	return nil
+}
+
+func (s *SQLStatistics) ListClients(ctx context.Context, filter *model.Client) ([]*model.Client, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	for _, column := range []struct {
+		name  string
+		value string
+	}{
+		{"client_name", filter.ClientName},
+		{"exchange_name", filter.ExchangeName},
+		{"label", filter.Label},
+		{"pair", filter.Pair},
+	} {
+		if column.value != "" {
+			conditions = append(conditions, column.name+" = ?")
+			args = append(args, column.value)
+		}
+	}
+
+	query := "SELECT " + clientColumns + ", registered_at FROM client"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + clientColumns
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*model.Client{}
+	for rows.Next() {
+		var client model.Client
+		if err := rows.Scan(&client.ClientName, &client.ExchangeName, &client.Label, &client.Pair, &client.RegisteredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row for clients: %w", err)
+		}
+		client.RegisteredAt = client.RegisteredAt.UTC()
+		clients = append(clients, &client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over client rows: %w", err)
+	}
+
+	return clients, nil
+}
+
+func (s *SQLStatistics) ClientRegistered(ctx context.Context, client *model.Client) (bool, error) {
+	var count int64
+	if err := s.queryRow(ctx, "SELECT COUNT(*) FROM client WHERE "+clientMatch,
+		client.ClientName, client.ExchangeName, client.Label, client.Pair).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to query client: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *SQLStatistics) DeleteClient(ctx context.Context, client *model.Client) error {
+	result, err := s.exec(ctx, "DELETE FROM client WHERE "+clientMatch,
+		client.ClientName, client.ExchangeName, client.Label, client.Pair)
+	if err != nil {
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete client: %w", err)
+	} else if deleted == 0 {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// createSQLSchemaMigrations records the applied versions. Unlike the
+// ClickHouse table it holds only applied migrations, because the relational
+// backends migrate forward at startup and have no rollback command.
+const createSQLSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)
+`
+
+// migrateSQL applies the pending migrations of dialect in one transaction.
+// It fails if an applied migration was modified or is no longer embedded.
+func migrateSQL(ctx context.Context, db *sql.DB, dialect sqlDialect) error {
+	migrations, err := migrate.LoadMigrationsFS(dialect.migrations)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dialect.lockMigrations != "" {
+		if _, err := tx.ExecContext(ctx, dialect.lockMigrations); err != nil {
+			return fmt.Errorf("failed to lock schema_migrations: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, createSQLSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT version, name, checksum FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	applied := make(map[uint64]migrate.AppliedMigration)
+	for rows.Next() {
+		state := migrate.AppliedMigration{Applied: true}
+		if err := rows.Scan(&state.Version, &state.Name, &state.Checksum); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row for schema_migrations: %w", err)
+		}
+		applied[state.Version] = state
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over schema_migrations rows: %w", err)
+	}
+
+	known := make(map[uint64]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		state, ok := applied[migration.Version]
+		if ok {
+			if state.Checksum != migration.Checksum {
+				return fmt.Errorf("migration %d_%s was modified after it was applied", migration.Version, migration.Name)
+			}
+			continue
+		}
+		slog.Info("applying migration", "storage", dialect.name, "version", migration.Version, "name", migration.Name)
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, dialect.rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	for version, state := range applied {
+		if !known[version] {
+			return fmt.Errorf("migration %d_%s is applied but not known to this build", version, state.Name)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package statistic
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
+	// Registers the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// NewSQLiteStatistics opens the SQLite database at cfg.Path in WAL mode,
+// creating the file and its schema when they do not exist.
+func NewSQLiteStatistics(cfg config.SQLite, m *metrics.Metrics) (*SQLStatistics, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	statistics, err := newSQLStatistics(db, sqlDialect{
+		name:       DriverSQLite,
+		rebind:     func(query string) string { return query },
+		migrations: migrations,
+	}, m)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return statistics, nil
+}
+
+// sqliteDSN sets the pragmas on every connection of the pool. WAL lets
+// readers run alongside the single writer, and immediate transactions take
+// the write lock up front so they wait out busy_timeout instead of failing
+// on upgrade. Times are stored in a text format that sorts chronologically.
+func sqliteDSN(cfg config.SQLite) string {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+	return "file:" + cfg.Path + "?" + params.Encode()
+}
@@ -0,0 +1,75 @@
+package statistic
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func newTestSQLiteStatistics(t *testing.T) IStatistics {
+	statistics, err := NewSQLiteStatistics(config.SQLite{
+		Path:        filepath.Join(t.TempDir(), "statistics.db"),
+		BusyTimeout: 5 * time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	return statistics
+}
+
+func TestSQLiteStatistics(t *testing.T) {
+	testStatistics(t, newTestSQLiteStatistics)
+}
+
+func TestSQLiteStatistics_Reopen(t *testing.T) {
+	cfg := config.SQLite{Path: filepath.Join(t.TempDir(), "statistics.db"), BusyTimeout: time.Second}
+	ctx := context.Background()
+
+	statistics, err := NewSQLiteStatistics(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	first := &model.HistoryOrder{Pair: "BTC/USDT", TimePlaced: time.Now()}
+	if err := statistics.SaveOrder(ctx, &model.Client{ClientName: "bot", ExchangeName: "binance", Pair: "BTC/USDT"}, first); err != nil {
+		t.Fatalf("failed to save order: %v", err)
+	}
+	var journalMode string
+	if err := statistics.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("expected WAL journal mode, but got %q (%v)", journalMode, err)
+	}
+	statistics.Close()
+
+	statistics, err = NewSQLiteStatistics(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen SQLite: %v", err)
+	}
+	defer statistics.Close()
+	second := &model.HistoryOrder{Pair: "BTC/USDT", TimePlaced: time.Now()}
+	if err := statistics.SaveOrder(ctx, &model.Client{ClientName: "bot", ExchangeName: "binance", Pair: "BTC/USDT"}, second); err != nil {
+		t.Fatalf("failed to save order: %v", err)
+	}
+	if second.ID <= first.ID {
+		t.Errorf("expected ids to keep increasing after reopening, but got %d after %d", second.ID, first.ID)
+	}
+	orders, err := statistics.GetOrderHistory(ctx, &model.Client{ClientName: "bot", ExchangeName: "binance", Pair: "BTC/USDT"})
+	if err != nil || len(orders) != 2 {
+		t.Errorf("expected both orders after reopening, but got %d (%v)", len(orders), err)
+	}
+}
+
+func TestSQLiteStatistics_SharedDatabase(t *testing.T) {
+	cfg := config.SQLite{Path: filepath.Join(t.TempDir(), "statistics.db"), BusyTimeout: 5 * time.Second}
+	var instances []*SQLStatistics
+	for i := 0; i < 2; i++ {
+		statistics, err := NewSQLiteStatistics(cfg, nil)
+		if err != nil {
+			t.Fatalf("failed to open SQLite: %v", err)
+		}
+		instances = append(instances, statistics)
+	}
+	testSharedSQLDatabase(t, instances...)
+}
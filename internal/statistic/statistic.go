@@ -1,13 +1,116 @@
 package statistic
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/config"
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/metrics"
 	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
 )
-type IStatistics interface{
-	
-	GetOrderBook(exchange_name, pair string) ([]*model.DepthOrder, error)
-	SaveOrderBook(exchange_name, pair string, orderBook []*model.DepthOrder) error
-	GetOrderHistory(client *model.Client)  ([]*model.HistoryOrder, error)
-	SaveOrder(client *model.Client, order *model.HistoryOrder) error
-
-}
\ No newline at end of file
+
+const (
+	DriverClickHouse = "clickhouse"
+	DriverMemory     = "memory"
+	DriverPostgres   = "postgres"
+	DriverSQLite     = "sqlite"
+)
+
+var (
+	ErrOrderBookNotFound = errors.New("order book snapshot not found")
+	ErrClientExists      = errors.New("client is already registered")
+	ErrClientNotFound    = errors.New("client is not registered")
+)
+
+// IStatistics is the storage behind the HTTP handlers. Every call is bound
+// to ctx: when it is cancelled or its deadline passes the call stops and
+// returns an error wrapping ctx.Err().
+type IStatistics interface {
+	GetOrderBook(ctx context.Context, exchange_name, pair string) (*model.OrderBook, error)
+	// GetOrderBookSnapshot returns the latest snapshot saved at or before at,
+	// or the latest snapshot overall when at is zero.
+	GetOrderBookSnapshot(ctx context.Context, exchange_name, pair string, at time.Time) (*model.OrderBook, error)
+	// ListOrderBookSnapshots returns up to limit snapshots saved within
+	// [from, to), oldest first. Zero times are not applied.
+	ListOrderBookSnapshots(ctx context.Context, exchange_name, pair string, from, to time.Time, limit int) ([]*model.OrderBook, error)
+	// SaveOrderBook stores orderBook as a new snapshot and sets its ID and SnapshotTime.
+	SaveOrderBook(ctx context.Context, orderBook *model.OrderBook) error
+	GetOrderHistory(ctx context.Context, client *model.Client) ([]*model.HistoryOrder, error)
+	// QueryOrderHistory returns one page of orders matching filter, oldest first.
+	QueryOrderHistory(ctx context.Context, filter *model.HistoryFilter) (*model.HistoryPage, error)
+	// SaveOrder stores order under client. It sets the client fields of order
+	// to the ones of client and assigns its ID and IngestedAt.
+	SaveOrder(ctx context.Context, client *model.Client, order *model.HistoryOrder) error
+	// SaveOrders inserts orders synchronously in a single batch and assigns
+	// their IDs and IngestedAt. The client tuple is taken from each order.
+	SaveOrders(ctx context.Context, orders []*model.HistoryOrder) error
+	// GetCandles aggregates order history of an exchange and pair into OHLCV candles.
+	GetCandles(ctx context.Context, query *model.CandleQuery) ([]*model.Candle, error)
+	// AlgorithmActivity aggregates the orders matching filter per
+	// algorithm, exchange and pair, sorted in that order. Limit and Cursor
+	// of filter are not applied.
+	AlgorithmActivity(ctx context.Context, filter *model.HistoryFilter) ([]*model.AlgorithmActivity, error)
+	// RegisterClient adds client to the registry and sets its RegisteredAt.
+	// It returns ErrClientExists if the same client is already registered.
+	RegisterClient(ctx context.Context, client *model.Client) error
+	// ListClients returns the registered clients matching filter, sorted by
+	// name. Empty fields of filter are not applied.
+	ListClients(ctx context.Context, filter *model.Client) ([]*model.Client, error)
+	// ClientRegistered reports whether exactly client is in the registry.
+	ClientRegistered(ctx context.Context, client *model.Client) (bool, error)
+	// DeleteClient removes client from the registry. It returns
+	// ErrClientNotFound if the client is not registered.
+	DeleteClient(ctx context.Context, client *model.Client) error
+	// Ready runs the readiness checks of the storage. It is ready when none
+	// of them failed; see ChecksPassed.
+	Ready(ctx context.Context) []Check
+	// Flush writes any buffered orders, giving up when ctx is done.
+	Flush(ctx context.Context) error
+	// Close flushes buffered writes and releases the storage connection.
+	Close() error
+}
+
+// NewStatistics creates the storage backend selected by storage.driver and
+// records its calls in m. An empty driver falls back to ClickHouse.
+func NewStatistics(cfg config.Config, m *metrics.Metrics) (IStatistics, error) {
+	var statistics IStatistics
+	switch cfg.Storage.Driver {
+	case "", DriverClickHouse:
+		expectedMigration, err := expectedMigrationVersion(cfg.Migrate.Dir)
+		if err != nil {
+			return nil, err
+		}
+		service, err := NewStatisticsService(cfg.ClickHouse, m)
+		if err != nil {
+			return nil, err
+		}
+		service.expectedMigration = expectedMigration
+		statistics = service
+	case DriverMemory:
+		statistics = NewMemoryStatistics()
+	case DriverPostgres:
+		service, err := NewPostgresStatistics(cfg.Postgres, m)
+		if err != nil {
+			return nil, err
+		}
+		statistics = service
+	case DriverSQLite:
+		service, err := NewSQLiteStatistics(cfg.SQLite, m)
+		if err != nil {
+			return nil, err
+		}
+		statistics = service
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+	return Instrument(statistics, m), nil
+}
+
+// BuffersOrderWrites reports whether the storage selected by cfg queues the
+// orders of SaveOrder for the batch writer, so SaveOrder returns before they
+// are stored.
+func BuffersOrderWrites(cfg config.Config) bool {
+	return (cfg.Storage.Driver == "" || cfg.Storage.Driver == DriverClickHouse) && cfg.ClickHouse.Batch.Enabled
+}
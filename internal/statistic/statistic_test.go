@@ -0,0 +1,620 @@
+package statistic
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+// testStatistics is the conformance suite every IStatistics backend must pass.
+// Each subtest uses its own exchange name so it can run against a shared database.
+func testStatistics(t *testing.T, newStatistics func(t *testing.T) IStatistics) {
+	uniqueExchange := func() string {
+		return fmt.Sprintf("test_exchange_%d", time.Now().UnixNano())
+	}
+	ctx := context.Background()
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := service.GetOrderBook(cancelled, uniqueExchange(), "BTC/USD"); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+		if err := service.SaveOrderBook(cancelled, &model.OrderBook{Exchange: uniqueExchange(), Pair: "BTC/USD"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, but got %v", err)
+		}
+	})
+
+	t.Run("GetOrderBook", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		orderBook, err := service.GetOrderBook(ctx, uniqueExchange(), "BTC/USD")
+		if err != nil {
+			t.Fatalf("GetOrderBook() error = %v", err)
+		}
+		if len(orderBook.Asks) != 0 || len(orderBook.Bids) != 0 {
+			t.Errorf("expected empty order book, but got %d asks and %d bids", len(orderBook.Asks), len(orderBook.Bids))
+		}
+	})
+
+	t.Run("SaveOrderBook", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		orderBook := &model.OrderBook{
+			Exchange: uniqueExchange(),
+			Pair:     "BTC/USD",
+			Asks: []model.DepthOrder{
+				{Price: 10000, BaseQty: 1},
+				{Price: 10100, BaseQty: 2},
+			},
+			Bids: []model.DepthOrder{
+				{Price: 9900, BaseQty: 3},
+			},
+		}
+
+		if err := service.SaveOrderBook(ctx, orderBook); err != nil {
+			t.Fatalf("SaveOrderBook() error = %v", err)
+		}
+
+		returnedOrderBook, err := service.GetOrderBook(ctx, orderBook.Exchange, orderBook.Pair)
+		if err != nil {
+			t.Fatalf("failed to get order book: %v", err)
+		}
+		assertDepthOrders(t, "asks", orderBook.Asks, returnedOrderBook.Asks)
+		assertDepthOrders(t, "bids", orderBook.Bids, returnedOrderBook.Bids)
+	})
+
+	t.Run("GetOrderBookFiltersByPair", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		btc := &model.OrderBook{Exchange: exchangeName, Pair: "BTC/USD", Bids: []model.DepthOrder{{Price: 9900, BaseQty: 3}}}
+		eth := &model.OrderBook{Exchange: exchangeName, Pair: "ETH/USD", Asks: []model.DepthOrder{{Price: 2000, BaseQty: 1}}}
+		for _, orderBook := range []*model.OrderBook{btc, eth} {
+			if err := service.SaveOrderBook(ctx, orderBook); err != nil {
+				t.Fatalf("SaveOrderBook() error = %v", err)
+			}
+		}
+
+		returnedOrderBook, err := service.GetOrderBook(ctx, exchangeName, "BTC/USD")
+		if err != nil {
+			t.Fatalf("failed to get order book: %v", err)
+		}
+		assertDepthOrders(t, "asks", btc.Asks, returnedOrderBook.Asks)
+		assertDepthOrders(t, "bids", btc.Bids, returnedOrderBook.Bids)
+	})
+
+	t.Run("GetOrderBookSnapshot", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		if _, err := service.GetOrderBookSnapshot(ctx, exchangeName, "BTC/USD", time.Time{}); !errors.Is(err, ErrOrderBookNotFound) {
+			t.Fatalf("expected ErrOrderBookNotFound, but got %v", err)
+		}
+
+		first := &model.OrderBook{Exchange: exchangeName, Pair: "BTC/USD", Asks: []model.DepthOrder{{Price: 10000, BaseQty: 1}}}
+		second := &model.OrderBook{Exchange: exchangeName, Pair: "BTC/USD", Asks: []model.DepthOrder{{Price: 10100, BaseQty: 2}}}
+		if err := service.SaveOrderBook(ctx, first); err != nil {
+			t.Fatalf("SaveOrderBook() error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if err := service.SaveOrderBook(ctx, second); err != nil {
+			t.Fatalf("SaveOrderBook() error = %v", err)
+		}
+		if first.SnapshotTime.IsZero() || !second.SnapshotTime.After(first.SnapshotTime) {
+			t.Errorf("expected increasing snapshot times, but got %v and %v", first.SnapshotTime, second.SnapshotTime)
+		}
+		if second.ID <= first.ID {
+			t.Errorf("expected increasing ids, but got %d and %d", first.ID, second.ID)
+		}
+
+		latest, err := service.GetOrderBookSnapshot(ctx, exchangeName, "BTC/USD", time.Time{})
+		if err != nil {
+			t.Fatalf("GetOrderBookSnapshot() error = %v", err)
+		}
+		if latest.ID != second.ID || !latest.SnapshotTime.Equal(second.SnapshotTime) {
+			t.Errorf("expected latest snapshot %d at %v, but got %d at %v", second.ID, second.SnapshotTime, latest.ID, latest.SnapshotTime)
+		}
+		assertDepthOrders(t, "asks", second.Asks, latest.Asks)
+
+		asOf, err := service.GetOrderBookSnapshot(ctx, exchangeName, "BTC/USD", first.SnapshotTime)
+		if err != nil {
+			t.Fatalf("GetOrderBookSnapshot() error = %v", err)
+		}
+		if asOf.ID != first.ID {
+			t.Errorf("expected snapshot %d as of %v, but got %d", first.ID, first.SnapshotTime, asOf.ID)
+		}
+		assertDepthOrders(t, "asks", first.Asks, asOf.Asks)
+
+		if _, err := service.GetOrderBookSnapshot(ctx, exchangeName, "BTC/USD", first.SnapshotTime.Add(-time.Second)); !errors.Is(err, ErrOrderBookNotFound) {
+			t.Errorf("expected ErrOrderBookNotFound before the first snapshot, but got %v", err)
+		}
+	})
+
+	t.Run("ListOrderBookSnapshots", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		var saved []*model.OrderBook
+		for i := 0; i < 3; i++ {
+			orderBook := &model.OrderBook{Exchange: exchangeName, Pair: "BTC/USD", Asks: []model.DepthOrder{{Price: float64(10000 + i), BaseQty: 1}}}
+			if err := service.SaveOrderBook(ctx, orderBook); err != nil {
+				t.Fatalf("SaveOrderBook() error = %v", err)
+			}
+			saved = append(saved, orderBook)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		orderBooks, err := service.ListOrderBookSnapshots(ctx, exchangeName, "BTC/USD", time.Time{}, time.Time{}, 0)
+		if err != nil {
+			t.Fatalf("ListOrderBookSnapshots() error = %v", err)
+		}
+		if len(orderBooks) != len(saved) {
+			t.Fatalf("expected %d snapshots, but got %d", len(saved), len(orderBooks))
+		}
+		for i, orderBook := range orderBooks {
+			if orderBook.ID != saved[i].ID {
+				t.Errorf("expected snapshot %d at position %d, but got %d", saved[i].ID, i, orderBook.ID)
+			}
+			assertDepthOrders(t, "asks", saved[i].Asks, orderBook.Asks)
+		}
+
+		orderBooks, err = service.ListOrderBookSnapshots(ctx, exchangeName, "BTC/USD", saved[1].SnapshotTime, saved[2].SnapshotTime, 0)
+		if err != nil {
+			t.Fatalf("ListOrderBookSnapshots() error = %v", err)
+		}
+		if len(orderBooks) != 1 || orderBooks[0].ID != saved[1].ID {
+			t.Errorf("expected only snapshot %d within [from, to), but got %d snapshots", saved[1].ID, len(orderBooks))
+		}
+
+		orderBooks, err = service.ListOrderBookSnapshots(ctx, exchangeName, "BTC/USD", time.Time{}, time.Time{}, 2)
+		if err != nil {
+			t.Fatalf("ListOrderBookSnapshots() error = %v", err)
+		}
+		if len(orderBooks) != 2 || orderBooks[0].ID != saved[0].ID {
+			t.Errorf("expected the 2 oldest snapshots, but got %d snapshots", len(orderBooks))
+		}
+	})
+
+	t.Run("GetOrderHistory", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		client := &model.Client{
+			ClientName:   "test_client",
+			ExchangeName: uniqueExchange(),
+			Label:        "test_label",
+			Pair:         "BTC/USD",
+		}
+
+		orderHistory, err := service.GetOrderHistory(ctx, client)
+		if err != nil {
+			t.Fatalf("GetOrderHistory() error = %v", err)
+		}
+		if len(orderHistory) != 0 {
+			t.Errorf("expected empty order history, but got %d orders", len(orderHistory))
+		}
+	})
+
+	t.Run("SaveOrder", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		client := &model.Client{
+			ClientName:   "test_client",
+			ExchangeName: uniqueExchange(),
+			Label:        "test_label",
+			Pair:         "BTC/USD",
+		}
+		order := &model.HistoryOrder{
+			ClientName:          "ignored_client",
+			ExchangeName:        "ignored_exchange",
+			Label:               "ignored_label",
+			Pair:                "ETH/USD",
+			Side:                "buy",
+			TypeOrder:           "limit",
+			BaseQty:             1,
+			Price:               10000,
+			AlgorithmNamePlaced: "test_algo",
+			LowestSellPrc:       10100,
+			HighestBuyPrc:       9900,
+			CommissionQuoteQty:  10,
+			TimePlaced:          time.Now().UTC().Truncate(time.Second),
+		}
+
+		if err := service.SaveOrder(ctx, client, order); err != nil {
+			t.Fatalf("SaveOrder() error = %v", err)
+		}
+		if order.ID == 0 || order.IngestedAt.IsZero() {
+			t.Errorf("expected SaveOrder() to assign an id and ingest time, but got %d and %v", order.ID, order.IngestedAt)
+		}
+		if order.ClientName != client.ClientName || order.Pair != client.Pair {
+			t.Errorf("expected SaveOrder() to set the client fields of the order, but got %v", order)
+		}
+
+		orderHistory, err := service.GetOrderHistory(ctx, client)
+		if err != nil {
+			t.Fatalf("GetOrderHistory() error = %v", err)
+		}
+		if len(orderHistory) != 1 {
+			t.Fatalf("expected 1 order, but got %d", len(orderHistory))
+		}
+
+		got := orderHistory[0]
+		if got.ClientName != client.ClientName || got.ExchangeName != client.ExchangeName ||
+			got.Label != client.Label || got.Pair != client.Pair {
+			t.Errorf("expected client %v, but got order %v", client, got)
+		}
+		if got.Side != order.Side || got.BaseQty != order.BaseQty || got.Price != order.Price ||
+			got.CommissionQuoteQty != order.CommissionQuoteQty || !got.TimePlaced.Equal(order.TimePlaced) {
+			t.Errorf("expected order %v, but got %v", order, got)
+		}
+		if got.ID != order.ID || !got.IngestedAt.Equal(order.IngestedAt) {
+			t.Errorf("expected id %d ingested at %v, but got %d ingested at %v", order.ID, order.IngestedAt, got.ID, got.IngestedAt)
+		}
+
+		other := *client
+		other.Label = "other_label"
+		orderHistory, err = service.GetOrderHistory(ctx, &other)
+		if err != nil {
+			t.Fatalf("GetOrderHistory() error = %v", err)
+		}
+		if len(orderHistory) != 0 {
+			t.Errorf("expected no orders for %v, but got %d", other, len(orderHistory))
+		}
+	})
+
+	t.Run("QueryOrderHistory", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		client := &model.Client{
+			ClientName:   "test_client",
+			ExchangeName: uniqueExchange(),
+			Label:        "test_label",
+			Pair:         "BTC/USD",
+		}
+		start := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+		orders := []*model.HistoryOrder{
+			{Side: "buy", TypeOrder: "limit", AlgorithmNamePlaced: "algo1", BaseQty: 1, Price: 100, TimePlaced: start},
+			{Side: "sell", TypeOrder: "limit", AlgorithmNamePlaced: "algo1", BaseQty: 2, Price: 101, TimePlaced: start},
+			{Side: "buy", TypeOrder: "market", AlgorithmNamePlaced: "algo2", BaseQty: 3, Price: 102, TimePlaced: start},
+			{Side: "sell", TypeOrder: "market", AlgorithmNamePlaced: "algo2", BaseQty: 4, Price: 103, TimePlaced: start.Add(time.Minute)},
+			{Side: "buy", TypeOrder: "limit", AlgorithmNamePlaced: "algo1", BaseQty: 5, Price: 104, TimePlaced: start.Add(2 * time.Minute)},
+		}
+		for _, order := range orders {
+			if err := service.SaveOrder(ctx, client, order); err != nil {
+				t.Fatalf("SaveOrder() error = %v", err)
+			}
+		}
+
+		var (
+			seen   []float64
+			cursor string
+		)
+		for pages := 0; ; pages++ {
+			if pages > len(orders)+2 {
+				t.Fatalf("pagination did not terminate")
+			}
+			page, err := service.QueryOrderHistory(ctx, &model.HistoryFilter{
+				ClientName:   client.ClientName,
+				ExchangeName: client.ExchangeName,
+				Limit:        2,
+				Cursor:       cursor,
+			})
+			if err != nil {
+				t.Fatalf("QueryOrderHistory() error = %v", err)
+			}
+			if len(page.Orders) > 2 {
+				t.Fatalf("expected at most 2 orders per page, but got %d", len(page.Orders))
+			}
+			for _, order := range page.Orders {
+				seen = append(seen, order.BaseQty)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+
+			if pages == 0 {
+				// Orders saved between pages are neither skipped nor repeated:
+				// one at the time of the cursor follows the returned orders and
+				// an earlier one sorts before the cursor.
+				for _, order := range []*model.HistoryOrder{
+					{Side: "buy", TypeOrder: "limit", AlgorithmNamePlaced: "algo1", BaseQty: 6, Price: 100, TimePlaced: start},
+					{Side: "buy", TypeOrder: "limit", AlgorithmNamePlaced: "algo1", BaseQty: 7, Price: 100, TimePlaced: start.Add(-time.Minute)},
+				} {
+					if err := service.SaveOrder(ctx, client, order); err != nil {
+						t.Fatalf("SaveOrder() error = %v", err)
+					}
+				}
+			}
+		}
+		// Orders at the same time are sorted by id, which follows the order
+		// in which they were saved.
+		expected := []float64{1, 2, 3, 6, 4, 5}
+		if fmt.Sprint(seen) != fmt.Sprint(expected) {
+			t.Errorf("expected orders %v across pages, but got %v", expected, seen)
+		}
+
+		page, err := service.QueryOrderHistory(ctx, &model.HistoryFilter{
+			ExchangeName: client.ExchangeName,
+			Side:         "buy",
+			From:         start.Add(time.Second),
+			To:           start.Add(3 * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("QueryOrderHistory() error = %v", err)
+		}
+		if len(page.Orders) != 1 || page.Orders[0].BaseQty != 5 || page.NextCursor != "" {
+			t.Errorf("expected only the last buy order, but got %d orders", len(page.Orders))
+		}
+
+		page, err = service.QueryOrderHistory(ctx, &model.HistoryFilter{
+			ExchangeName:        client.ExchangeName,
+			TypeOrder:           "market",
+			AlgorithmNamePlaced: "algo2",
+			To:                  start.Add(time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("QueryOrderHistory() error = %v", err)
+		}
+		if len(page.Orders) != 1 || page.Orders[0].BaseQty != 3 {
+			t.Errorf("expected only the first market order, but got %d orders", len(page.Orders))
+		}
+
+		for _, cursor := range []string{
+			"not a cursor",
+			// A cursor of the former offset based format.
+			base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-06-28T12:00:00Z","s":2}`)),
+		} {
+			if _, err := service.QueryOrderHistory(ctx, &model.HistoryFilter{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor for cursor %q, but got %v", cursor, err)
+			}
+		}
+	})
+
+	t.Run("SaveOrders", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		timePlaced := time.Now().UTC().Truncate(time.Second)
+		orders := []*model.HistoryOrder{
+			{ClientName: "alice", ExchangeName: exchangeName, Label: "l1", Pair: "BTC/USD", Side: "buy", BaseQty: 1, TimePlaced: timePlaced},
+			{ClientName: "bob", ExchangeName: exchangeName, Label: "l2", Pair: "ETH/USD", Side: "sell", BaseQty: 2, TimePlaced: timePlaced},
+		}
+		if err := service.SaveOrders(ctx, orders); err != nil {
+			t.Fatalf("SaveOrders() error = %v", err)
+		}
+		if orders[0].ID == 0 || orders[1].ID <= orders[0].ID {
+			t.Errorf("expected increasing ids to be assigned, but got %d and %d", orders[0].ID, orders[1].ID)
+		}
+
+		for _, order := range orders {
+			orderHistory, err := service.GetOrderHistory(ctx, &model.Client{
+				ClientName:   order.ClientName,
+				ExchangeName: order.ExchangeName,
+				Label:        order.Label,
+				Pair:         order.Pair,
+			})
+			if err != nil {
+				t.Fatalf("GetOrderHistory() error = %v", err)
+			}
+			if len(orderHistory) != 1 || orderHistory[0].BaseQty != order.BaseQty {
+				t.Errorf("expected order %v to be saved, but got %d orders", order, len(orderHistory))
+			}
+		}
+	})
+
+	t.Run("Clients", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		clients := []*model.Client{
+			{ClientName: "bob", ExchangeName: exchangeName, Label: "l1", Pair: "ETH/USD"},
+			{ClientName: "alice", ExchangeName: exchangeName, Label: "l1", Pair: "BTC/USD"},
+			{ClientName: "alice", ExchangeName: exchangeName, Label: "l2", Pair: "BTC/USD"},
+		}
+		for _, client := range clients {
+			if err := service.RegisterClient(ctx, client); err != nil {
+				t.Fatalf("RegisterClient() error = %v", err)
+			}
+			if client.RegisteredAt.IsZero() {
+				t.Errorf("expected RegisterClient() to set RegisteredAt of %v", client)
+			}
+		}
+		duplicate := *clients[0]
+		if err := service.RegisterClient(ctx, &duplicate); !errors.Is(err, ErrClientExists) {
+			t.Errorf("expected ErrClientExists, but got %v", err)
+		}
+
+		listed, err := service.ListClients(ctx, &model.Client{ExchangeName: exchangeName})
+		if err != nil {
+			t.Fatalf("ListClients() error = %v", err)
+		}
+		var labels []string
+		for _, client := range listed {
+			labels = append(labels, client.ClientName+"/"+client.Label)
+		}
+		if fmt.Sprint(labels) != "[alice/l1 alice/l2 bob/l1]" {
+			t.Errorf("expected clients sorted by name, but got %v", labels)
+		}
+
+		listed, err = service.ListClients(ctx, &model.Client{ExchangeName: exchangeName, Pair: "ETH/USD"})
+		if err != nil {
+			t.Fatalf("ListClients() error = %v", err)
+		}
+		if len(listed) != 1 || listed[0].ClientName != "bob" || !listed[0].RegisteredAt.Equal(clients[0].RegisteredAt) {
+			t.Errorf("expected only bob, but got %v", listed)
+		}
+
+		unlabelled := model.Client{ClientName: "alice", ExchangeName: exchangeName, Pair: "BTC/USD"}
+		if registered, err := service.ClientRegistered(ctx, &unlabelled); err != nil || registered {
+			t.Errorf("expected a client with another label to be unregistered, but got %v, %v", registered, err)
+		}
+
+		if err := service.DeleteClient(ctx, clients[1]); err != nil {
+			t.Fatalf("DeleteClient() error = %v", err)
+		}
+		if registered, err := service.ClientRegistered(ctx, clients[1]); err != nil || registered {
+			t.Errorf("expected a deleted client to be unregistered, but got %v, %v", registered, err)
+		}
+		if registered, err := service.ClientRegistered(ctx, clients[2]); err != nil || !registered {
+			t.Errorf("expected other clients to stay registered, but got %v, %v", registered, err)
+		}
+		if err := service.DeleteClient(ctx, clients[1]); !errors.Is(err, ErrClientNotFound) {
+			t.Errorf("expected ErrClientNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("AlgorithmActivity", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		timePlaced := time.Now().UTC().Truncate(time.Second)
+		orders := []*model.HistoryOrder{
+			{AlgorithmNamePlaced: "twap", Pair: "BTC/USD", Side: "buy", BaseQty: 2, Price: 100, CommissionQuoteQty: 0.2, LowestSellPrc: 100, HighestBuyPrc: 99},
+			{AlgorithmNamePlaced: "twap", Pair: "BTC/USD", Side: "sell", BaseQty: 1, Price: 110, CommissionQuoteQty: 0.1, LowestSellPrc: 111, HighestBuyPrc: 110},
+			// No top of book, so it has no slippage.
+			{AlgorithmNamePlaced: "twap", Pair: "BTC/USD", Side: "buy", BaseQty: 1, Price: 101},
+			{AlgorithmNamePlaced: "twap", Pair: "ETH/USD", Side: "sell", BaseQty: 3, Price: 10, LowestSellPrc: 11, HighestBuyPrc: 10},
+			{AlgorithmNamePlaced: "sniper", Pair: "BTC/USD", Side: "buy", BaseQty: 1, Price: 101, LowestSellPrc: 100, HighestBuyPrc: 99},
+			// Before from.
+			{AlgorithmNamePlaced: "sniper", Pair: "BTC/USD", Side: "buy", BaseQty: 1, Price: 100, TimePlaced: timePlaced.Add(-time.Hour)},
+		}
+		for _, order := range orders {
+			order.ClientName, order.ExchangeName = "test_client", exchangeName
+			if order.TimePlaced.IsZero() {
+				order.TimePlaced = timePlaced
+			}
+		}
+		if err := service.SaveOrders(ctx, orders); err != nil {
+			t.Fatalf("SaveOrders() error = %v", err)
+		}
+
+		activity, err := service.AlgorithmActivity(ctx, &model.HistoryFilter{ExchangeName: exchangeName, From: timePlaced})
+		if err != nil {
+			t.Fatalf("AlgorithmActivity() error = %v", err)
+		}
+		expected := []model.AlgorithmActivity{
+			{AlgorithmNamePlaced: "sniper", ExchangeName: exchangeName, Pair: "BTC/USD", Fills: 1,
+				BuyQty: 1, BuyNotional: 101, SlippageBps: 100, SlippageOrders: 1},
+			{AlgorithmNamePlaced: "twap", ExchangeName: exchangeName, Pair: "BTC/USD", Fills: 3,
+				BuyQty: 3, BuyNotional: 301, SellQty: 1, SellNotional: 110, Commission: 0.3, SlippageOrders: 2},
+			{AlgorithmNamePlaced: "twap", ExchangeName: exchangeName, Pair: "ETH/USD", Fills: 1,
+				SellQty: 3, SellNotional: 30, SlippageOrders: 1},
+		}
+		if len(activity) != len(expected) {
+			t.Fatalf("expected %d rows, but got %d", len(expected), len(activity))
+		}
+		for i, a := range activity {
+			e := expected[i]
+			if a.AlgorithmNamePlaced != e.AlgorithmNamePlaced || a.ExchangeName != e.ExchangeName || a.Pair != e.Pair ||
+				a.Fills != e.Fills || a.SlippageOrders != e.SlippageOrders ||
+				math.Abs(a.BuyQty-e.BuyQty) > 1e-9 || math.Abs(a.BuyNotional-e.BuyNotional) > 1e-9 ||
+				math.Abs(a.SellQty-e.SellQty) > 1e-9 || math.Abs(a.SellNotional-e.SellNotional) > 1e-9 ||
+				math.Abs(a.Commission-e.Commission) > 1e-9 || math.Abs(a.SlippageBps-e.SlippageBps) > 1e-9 {
+				t.Errorf("expected activity %+v, but got %+v", e, *a)
+			}
+		}
+	})
+
+	t.Run("GetCandles", func(t *testing.T) {
+		service := newStatistics(t)
+		defer service.Close()
+
+		exchangeName := uniqueExchange()
+		base := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+		orders := []*model.HistoryOrder{
+			{Price: 100, BaseQty: 1, TimePlaced: base},
+			{Price: 105, BaseQty: 2, TimePlaced: base.Add(time.Minute)},
+			{Price: 95, BaseQty: 1, TimePlaced: base.Add(3 * time.Minute)},
+			{Price: 110, BaseQty: 3, TimePlaced: base.Add(6 * time.Minute)},
+		}
+		for _, order := range orders {
+			order.ClientName, order.ExchangeName, order.Pair, order.Side = "test_client", exchangeName, "BTC/USD", "buy"
+		}
+		if err := service.SaveOrders(ctx, orders); err != nil {
+			t.Fatalf("SaveOrders() error = %v", err)
+		}
+
+		candles, err := service.GetCandles(ctx, &model.CandleQuery{
+			ExchangeName: exchangeName,
+			Pair:         "BTC/USD",
+			Interval:     5 * time.Minute,
+			From:         base,
+			To:           base.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("GetCandles() error = %v", err)
+		}
+		expected := []model.Candle{
+			{Time: base, Open: 100, High: 105, Low: 95, Close: 95, Volume: 4, Trades: 3},
+			{Time: base.Add(5 * time.Minute), Open: 110, High: 110, Low: 110, Close: 110, Volume: 3, Trades: 1},
+		}
+		if len(candles) != len(expected) {
+			t.Fatalf("expected %d candles, but got %d", len(expected), len(candles))
+		}
+		for i, candle := range candles {
+			if !candle.Time.Equal(expected[i].Time) || candle.Open != expected[i].Open || candle.High != expected[i].High ||
+				candle.Low != expected[i].Low || candle.Close != expected[i].Close ||
+				candle.Volume != expected[i].Volume || candle.Trades != expected[i].Trades {
+				t.Errorf("expected candle %+v, but got %+v", expected[i], *candle)
+			}
+		}
+
+		candles, err = service.GetCandles(ctx, &model.CandleQuery{
+			ExchangeName: exchangeName,
+			Pair:         "BTC/USD",
+			Interval:     5 * time.Minute,
+			From:         base.Add(time.Minute),
+			To:           base.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("GetCandles() error = %v", err)
+		}
+		if len(candles) != 1 || !candles[0].Time.Equal(base.Add(5*time.Minute)) {
+			t.Errorf("expected only the candle starting after from, but got %d candles", len(candles))
+		}
+
+		_, err = service.GetCandles(ctx, &model.CandleQuery{
+			ExchangeName: exchangeName,
+			Pair:         "BTC/USD",
+			Interval:     30 * time.Second,
+			From:         base,
+			To:           base.Add(time.Hour),
+		})
+		if !errors.Is(err, ErrInvalidCandleQuery) {
+			t.Errorf("expected ErrInvalidCandleQuery, but got %v", err)
+		}
+	})
+}
+
+func assertDepthOrders(t *testing.T, side string, expected, got []model.DepthOrder) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d %s, but got %d", len(expected), side, len(got))
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("expected %s[%d] %v, but got %v", side, i, expected[i], got[i])
+		}
+	}
+}
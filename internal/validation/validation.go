@@ -0,0 +1,206 @@
+// Package validation checks requests against the rules of the model package
+// and reports every invalid field at once.
+package validation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+var (
+	// Sides are the accepted values of HistoryOrder.Side.
+	Sides = []string{"buy", "sell"}
+	// OrderTypes are the accepted values of HistoryOrder.TypeOrder. It may
+	// also be left empty.
+	OrderTypes = []string{"limit", "market", "stop", "stop_limit"}
+
+	// MinTime is the earliest accepted order time.
+	MinTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// MaxClockSkew is how far in the future an order time may be, to allow
+	// for clocks of clients running ahead.
+	MaxClockSkew = 5 * time.Minute
+)
+
+// pairFormat matches pairs such as BTC/USD or 1INCH/USDT.
+var pairFormat = regexp.MustCompile(`^[A-Z0-9]{2,10}/[A-Z0-9]{2,10}$`)
+
+// FieldError is a problem with a single field, named as in JSON.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns e as an error, or nil when there is nothing to report.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// OrderBook checks the exchange, the pair and every depth level.
+func OrderBook(orderBook *model.OrderBook) error {
+	var errs Errors
+	required(&errs, "exchange", orderBook.Exchange)
+	pair(&errs, "pair", orderBook.Pair)
+	for i, ask := range orderBook.Asks {
+		depthOrder(&errs, fmt.Sprintf("asks[%d].", i), ask)
+	}
+	for i, bid := range orderBook.Bids {
+		depthOrder(&errs, fmt.Sprintf("bids[%d].", i), bid)
+	}
+	return errs.err()
+}
+
+// DepthOrder checks that the price and quantity of a level are positive.
+func DepthOrder(order model.DepthOrder) error {
+	var errs Errors
+	depthOrder(&errs, "", order)
+	return errs.err()
+}
+
+// HistoryOrder checks the client fields, side, type, amounts and time of an order.
+func HistoryOrder(order *model.HistoryOrder) error {
+	var errs Errors
+	client(&errs, model.Client{
+		ClientName:   order.ClientName,
+		ExchangeName: order.ExchangeName,
+		Label:        order.Label,
+		Pair:         order.Pair,
+	})
+	if order.Side == "" {
+		errs.add("side", "is required")
+	} else {
+		oneOf(&errs, "side", order.Side, Sides)
+	}
+	if order.TypeOrder != "" {
+		oneOf(&errs, "type_order", order.TypeOrder, OrderTypes)
+	}
+	positive(&errs, "base_qty", order.BaseQty)
+	positive(&errs, "price", order.Price)
+	nonNegative(&errs, "lowest_sell_prc", order.LowestSellPrc)
+	nonNegative(&errs, "highest_buy_prc", order.HighestBuyPrc)
+	nonNegative(&errs, "commission_quote_qty", order.CommissionQuoteQty)
+	orderTime(&errs, "time_placed", order.TimePlaced)
+	return errs.err()
+}
+
+// Client checks that a client names its exchange and a valid pair. The
+// label is optional.
+func Client(c *model.Client) error {
+	var errs Errors
+	client(&errs, *c)
+	return errs.err()
+}
+
+// Market checks the exchange_name and pair query parameters shared by the
+// read endpoints.
+func Market(exchangeName, pairName string) error {
+	var errs Errors
+	required(&errs, "exchange_name", exchangeName)
+	pair(&errs, "pair", pairName)
+	return errs.err()
+}
+
+// Pair checks the format of the pair query parameter.
+func Pair(pairName string) error {
+	var errs Errors
+	pair(&errs, "pair", pairName)
+	return errs.err()
+}
+
+// HistoryFilter checks the fields of filter that are set.
+func HistoryFilter(filter *model.HistoryFilter) error {
+	var errs Errors
+	if filter.Pair != "" {
+		pair(&errs, "pair", filter.Pair)
+	}
+	if filter.Side != "" {
+		oneOf(&errs, "side", filter.Side, Sides)
+	}
+	if filter.TypeOrder != "" {
+		oneOf(&errs, "type_order", filter.TypeOrder, OrderTypes)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		errs.add("to", "must be after from")
+	}
+	return errs.err()
+}
+
+func client(errs *Errors, c model.Client) {
+	required(errs, "client_name", c.ClientName)
+	required(errs, "exchange_name", c.ExchangeName)
+	pair(errs, "pair", c.Pair)
+}
+
+func depthOrder(errs *Errors, prefix string, order model.DepthOrder) {
+	positive(errs, prefix+"price", order.Price)
+	positive(errs, prefix+"base_qty", order.BaseQty)
+}
+
+func required(errs *Errors, field, value string) {
+	if strings.TrimSpace(value) == "" {
+		errs.add(field, "is required")
+	}
+}
+
+func pair(errs *Errors, field, value string) {
+	switch {
+	case value == "":
+		errs.add(field, "is required")
+	case !pairFormat.MatchString(value):
+		errs.add(field, "must be BASE/QUOTE in upper case, such as BTC/USD")
+	}
+}
+
+func oneOf(errs *Errors, field, value string, allowed []string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	errs.add(field, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+func positive(errs *Errors, field string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value <= 0 {
+		errs.add(field, "must be a positive number")
+	}
+}
+
+func nonNegative(errs *Errors, field string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+		errs.add(field, "must be a non-negative number")
+	}
+}
+
+func orderTime(errs *Errors, field string, value time.Time) {
+	switch {
+	case value.IsZero():
+		errs.add(field, "is required")
+	case value.Before(MinTime):
+		errs.add(field, "must not be before %s", MinTime.Format(time.RFC3339))
+	case value.After(time.Now().Add(MaxClockSkew)):
+		errs.add(field, "must not be more than %v in the future", MaxClockSkew)
+	}
+}
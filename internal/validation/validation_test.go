@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mbatimel/HW_Statistics_collection_service/internal/model"
+)
+
+func validOrder() *model.HistoryOrder {
+	return &model.HistoryOrder{
+		ClientName:   "Alice",
+		ExchangeName: "Binance",
+		Label:        "Order1",
+		Pair:         "BTC/USD",
+		Side:         "buy",
+		TypeOrder:    "limit",
+		BaseQty:      0.1,
+		Price:        10000.5,
+		TimePlaced:   time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// fields returns the names of the invalid fields reported by err.
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation Errors, but got %T: %v", err, err)
+	}
+	var names []string
+	for _, fieldErr := range errs {
+		names = append(names, fieldErr.Field)
+	}
+	return names
+}
+
+func TestHistoryOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(order *model.HistoryOrder)
+		fields []string
+	}{
+		{"valid", func(order *model.HistoryOrder) {}, nil},
+		{"no type", func(order *model.HistoryOrder) { order.TypeOrder = "" }, nil},
+		{"missing client", func(order *model.HistoryOrder) { order.ClientName = "" }, []string{"client_name"}},
+		{"lower case pair", func(order *model.HistoryOrder) { order.Pair = "btc/usd" }, []string{"pair"}},
+		{"pair without quote", func(order *model.HistoryOrder) { order.Pair = "BTCUSD" }, []string{"pair"}},
+		{"unknown side", func(order *model.HistoryOrder) { order.Side = "long" }, []string{"side"}},
+		{"unknown type", func(order *model.HistoryOrder) { order.TypeOrder = "iceberg" }, []string{"type_order"}},
+		{"negative quantity", func(order *model.HistoryOrder) { order.BaseQty = -1 }, []string{"base_qty"}},
+		{"NaN price", func(order *model.HistoryOrder) { order.Price = math.NaN() }, []string{"price"}},
+		{"infinite commission", func(order *model.HistoryOrder) { order.CommissionQuoteQty = math.Inf(1) }, []string{"commission_quote_qty"}},
+		{"zero time", func(order *model.HistoryOrder) { order.TimePlaced = time.Time{} }, []string{"time_placed"}},
+		{"time too old", func(order *model.HistoryOrder) { order.TimePlaced = MinTime.Add(-time.Second) }, []string{"time_placed"}},
+		{"time in the future", func(order *model.HistoryOrder) { order.TimePlaced = time.Now().Add(time.Hour) }, []string{"time_placed"}},
+		{"several fields", func(order *model.HistoryOrder) {
+			order.ExchangeName = ""
+			order.Side = ""
+			order.BaseQty = 0
+		}, []string{"exchange_name", "side", "base_qty"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(order)
+			if got := fields(t, HistoryOrder(order)); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("expected invalid fields %v, but got %v", tt.fields, got)
+			}
+		})
+	}
+}
+
+func TestOrderBook(t *testing.T) {
+	orderBook := &model.OrderBook{
+		Exchange: "Binance",
+		Pair:     "BTC/USD",
+		Asks:     []model.DepthOrder{{Price: 10001, BaseQty: 1}, {Price: 10002, BaseQty: 0}},
+		Bids:     []model.DepthOrder{{Price: -9999, BaseQty: 1}},
+	}
+	expected := []string{"asks[1].base_qty", "bids[0].price"}
+	if got := fields(t, OrderBook(orderBook)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected invalid fields %v, but got %v", expected, got)
+	}
+
+	if got := fields(t, OrderBook(&model.OrderBook{})); !reflect.DeepEqual(got, []string{"exchange", "pair"}) {
+		t.Errorf("expected exchange and pair to be required, but got %v", got)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	if err := HistoryFilter(&model.HistoryFilter{}); err != nil {
+		t.Errorf("expected an empty filter to be valid, but got %v", err)
+	}
+
+	from := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	filter := &model.HistoryFilter{Side: "short", From: from, To: from}
+	if got := fields(t, HistoryFilter(filter)); !reflect.DeepEqual(got, []string{"side", "to"}) {
+		t.Errorf("expected side and to to be invalid, but got %v", got)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS Client;
+DROP TABLE IF EXISTS HistoryOrder;
+DROP TABLE IF EXISTS OrderBook;
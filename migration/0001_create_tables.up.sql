@@ -1,12 +1,15 @@
--- create_tables.sql
 CREATE TABLE IF NOT EXISTS OrderBook (
     id Int64,
     exchange String,
     pair String,
+    snapshot_time DateTime64(3, 'UTC'),
     asks Array(Tuple(Float64, Float64)),
     bids Array(Tuple(Float64, Float64))
 ) ENGINE = MergeTree()
-ORDER BY id;
+ORDER BY (exchange, pair, snapshot_time, id);
+
+-- Databases created before order book snapshots were timestamped.
+ALTER TABLE OrderBook ADD COLUMN IF NOT EXISTS snapshot_time DateTime64(3, 'UTC') AFTER pair;
 
 CREATE TABLE IF NOT EXISTS HistoryOrder (
     client_name String,
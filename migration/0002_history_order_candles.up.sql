@@ -0,0 +1,16 @@
+CREATE MATERIALIZED VIEW IF NOT EXISTS HistoryOrderCandles
+ENGINE = AggregatingMergeTree()
+ORDER BY (exchange_name, pair, bucket)
+POPULATE
+AS SELECT
+    exchange_name,
+    pair,
+    toStartOfMinute(time_placed) AS bucket,
+    argMinState(price, time_placed) AS open,
+    maxState(price) AS high,
+    minState(price) AS low,
+    argMaxState(price, time_placed) AS close,
+    sumState(base_qty) AS volume,
+    countState() AS trades
+FROM HistoryOrder
+GROUP BY exchange_name, pair, bucket;
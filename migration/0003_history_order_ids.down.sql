@@ -0,0 +1,2 @@
+ALTER TABLE HistoryOrder DROP COLUMN IF EXISTS ingested_at;
+ALTER TABLE HistoryOrder DROP COLUMN IF EXISTS id;
@@ -0,0 +1,3 @@
+-- Orders saved before this migration keep id 0 and ingested_at 1970-01-01.
+ALTER TABLE HistoryOrder ADD COLUMN IF NOT EXISTS id Int64 FIRST;
+ALTER TABLE HistoryOrder ADD COLUMN IF NOT EXISTS ingested_at DateTime64(3, 'UTC') AFTER time_placed;
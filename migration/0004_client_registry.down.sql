@@ -0,0 +1 @@
+ALTER TABLE Client DROP COLUMN IF EXISTS registered_at;
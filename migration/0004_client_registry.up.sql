@@ -0,0 +1,2 @@
+-- Clients registered before this migration have registered_at 1970-01-01.
+ALTER TABLE Client ADD COLUMN IF NOT EXISTS registered_at DateTime64(3, 'UTC');
@@ -0,0 +1,49 @@
+-- Truncates time_placed back to seconds. Ids given to older orders are kept.
+DROP TABLE IF EXISTS HistoryOrder_0005;
+
+CREATE TABLE HistoryOrder_0005 (
+    id Int64,
+    client_name String,
+    exchange_name String,
+    label String,
+    pair String,
+    side String,
+    type_order String,
+    base_qty Float64,
+    price Float64,
+    algorithm_name_placed String,
+    lowest_sell_prc Float64,
+    highest_buy_prc Float64,
+    commission_quote_qty Float64,
+    time_placed DateTime,
+    ingested_at DateTime64(3, 'UTC')
+) ENGINE = MergeTree()
+ORDER BY (client_name, exchange_name, time_placed);
+
+INSERT INTO HistoryOrder_0005
+SELECT
+    id, client_name, exchange_name, label, pair, side, type_order,
+    base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
+    commission_quote_qty, toDateTime(time_placed), ingested_at
+FROM HistoryOrder;
+
+DROP VIEW IF EXISTS HistoryOrderCandles;
+DROP TABLE HistoryOrder;
+RENAME TABLE HistoryOrder_0005 TO HistoryOrder;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS HistoryOrderCandles
+ENGINE = AggregatingMergeTree()
+ORDER BY (exchange_name, pair, bucket)
+POPULATE
+AS SELECT
+    exchange_name,
+    pair,
+    toStartOfMinute(time_placed) AS bucket,
+    argMinState(price, time_placed) AS open,
+    maxState(price) AS high,
+    minState(price) AS low,
+    argMaxState(price, time_placed) AS close,
+    sumState(base_qty) AS volume,
+    countState() AS trades
+FROM HistoryOrder
+GROUP BY exchange_name, pair, bucket;
@@ -0,0 +1,54 @@
+-- Stores time_placed in milliseconds and adds id to the sorting key, so order
+-- history can be paged by (time_placed, id). The table is copied, so stop the
+-- writers while this migration runs. Orders saved before
+-- 0003_history_order_ids get unique negative ids in place of 0, which cannot
+-- clash with the ids handed out since.
+DROP TABLE IF EXISTS HistoryOrder_0005;
+
+CREATE TABLE HistoryOrder_0005 (
+    id Int64,
+    client_name String,
+    exchange_name String,
+    label String,
+    pair String,
+    side String,
+    type_order String,
+    base_qty Float64,
+    price Float64,
+    algorithm_name_placed String,
+    lowest_sell_prc Float64,
+    highest_buy_prc Float64,
+    commission_quote_qty Float64,
+    time_placed DateTime64(3, 'UTC'),
+    ingested_at DateTime64(3, 'UTC')
+) ENGINE = MergeTree()
+ORDER BY (client_name, exchange_name, time_placed, id);
+
+INSERT INTO HistoryOrder_0005
+SELECT
+    if(id = 0, -toInt64(rowNumberInAllBlocks()) - 1, id),
+    client_name, exchange_name, label, pair, side, type_order,
+    base_qty, price, algorithm_name_placed, lowest_sell_prc, highest_buy_prc,
+    commission_quote_qty, toDateTime64(time_placed, 3, 'UTC'), ingested_at
+FROM HistoryOrder;
+
+DROP VIEW IF EXISTS HistoryOrderCandles;
+DROP TABLE HistoryOrder;
+RENAME TABLE HistoryOrder_0005 TO HistoryOrder;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS HistoryOrderCandles
+ENGINE = AggregatingMergeTree()
+ORDER BY (exchange_name, pair, bucket)
+POPULATE
+AS SELECT
+    exchange_name,
+    pair,
+    toStartOfMinute(toDateTime(time_placed, 'UTC')) AS bucket,
+    argMinState(price, time_placed) AS open,
+    maxState(price) AS high,
+    minState(price) AS low,
+    argMaxState(price, time_placed) AS close,
+    sumState(base_qty) AS volume,
+    countState() AS trades
+FROM HistoryOrder
+GROUP BY exchange_name, pair, bucket;
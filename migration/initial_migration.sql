@@ -1,4 +1,6 @@
 -- initial_migration.sql
-CREATE USER IF NOT EXISTS my_user IDENTIFIED BY 'my_password';
-GRANT ALL ON my_database.* TO my_user;
-CREATE DATABASE IF NOT EXISTS my_database;
+-- Run by the migration tool with the admin credentials before the versioned
+-- migrations. The placeholders are filled in from the clickhouse config.
+CREATE DATABASE IF NOT EXISTS `${CLICKHOUSE_DB}`;
+CREATE USER IF NOT EXISTS `${CLICKHOUSE_USERNAME}` IDENTIFIED BY '${CLICKHOUSE_PASSWORD}';
+GRANT ALL ON `${CLICKHOUSE_DB}`.* TO `${CLICKHOUSE_USERNAME}`;